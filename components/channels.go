@@ -3,9 +3,12 @@ package components
 import (
 	"fmt"
 	"html"
+	"time"
 
 	"github.com/erroneousboat/termui"
 	"github.com/lithammer/fuzzysearch/fuzzy"
+
+	"github.com/erroneousboat/slack-term/config"
 )
 
 const (
@@ -35,6 +38,17 @@ type ChannelItem struct {
 	Presence     string
 	Notification bool
 
+	// UnreadCount and MentionCount are the amount of unread messages and
+	// mentions, Latest is the time of the latest message
+	UnreadCount  int
+	MentionCount int
+	Latest       time.Time
+
+	// Muted and Level are the notification preferences of the channel in
+	// slack, Level is empty when the channel doesn't have one
+	Muted bool
+	Level string
+
 	StylePrefix string
 	StyleIcon   string
 	StyleText   string
@@ -51,33 +65,37 @@ func (c ChannelItem) ToString() string {
 		prefix = " "
 	}
 
-	var icon string
+	label := fmt.Sprintf(
+		"[%s](%s) [%s](%s) [%s](%s)",
+		prefix, c.StylePrefix,
+		c.GetIcon(), c.StyleIcon,
+		c.Name, c.StyleText,
+	)
+
+	return label
+}
+
+// GetIcon returns the icon of the channel, based on its type and, for
+// direct messages, the presence of the user
+func (c ChannelItem) GetIcon() string {
 	switch c.Type {
 	case ChannelTypeChannel:
-		icon = IconChannel
+		return IconChannel
 	case ChannelTypeGroup:
-		icon = IconGroup
+		return IconGroup
 	case ChannelTypeMpIM:
-		icon = IconMpIM
+		return IconMpIM
 	case ChannelTypeIM:
 		switch c.Presence {
 		case PresenceActive:
-			icon = IconOnline
+			return IconOnline
 		case PresenceAway:
-			icon = IconOffline
+			return IconOffline
 		default:
-			icon = IconIM
+			return IconIM
 		}
 	}
-
-	label := fmt.Sprintf(
-		"[%s](%s) [%s](%s) [%s](%s)",
-		prefix, c.StylePrefix,
-		icon, c.StyleIcon,
-		c.Name, c.StyleText,
-	)
-
-	return label
+	return ""
 }
 
 // GetChannelName will return a formatted representation of the
@@ -95,16 +113,51 @@ func (c ChannelItem) GetChannelName() string {
 	return channelName
 }
 
+// UnreadItem is a channel with unread messages in the Unreads section of
+// the Channels pane, the Workspace is set when there are multiple
+type UnreadItem struct {
+	Workspace string
+	Channel   ChannelItem
+}
+
+// ToString will set the label of the unread channel, with the amount of
+// unread messages, or mentions when there are any
+func (u UnreadItem) ToString() string {
+	name := u.Channel.Name
+	if u.Workspace != "" {
+		name = u.Workspace + "/" + name
+	}
+
+	var count string
+	if u.Channel.MentionCount > 0 {
+		count = fmt.Sprintf("@%d", u.Channel.MentionCount)
+	} else if u.Channel.UnreadCount > 0 {
+		count = fmt.Sprintf("%d", u.Channel.UnreadCount)
+	}
+
+	return fmt.Sprintf(
+		"[%s](%s) [%s](%s) [%s](%s) [%s](fg-bold)",
+		IconNotification, u.Channel.StylePrefix,
+		u.Channel.GetIcon(), u.Channel.StyleIcon,
+		name, u.Channel.StyleText,
+		count,
+	)
+}
+
 // Channels is the definition of a Channels component
 type Channels struct {
 	ChannelItems    []ChannelItem
+	UnreadItems     []UnreadItem // channels shown in the Unreads section
 	List            *termui.List
 	SelectedChannel int // index of which channel is selected from the List
-	Offset          int // from what offset are channels rendered
+	Offset          int // from what row are channels rendered
 	CursorPosition  int // the y position of the 'cursor'
 
 	SearchMatches  []int // index of the search matches
 	SearchPosition int   // current position of a search match
+
+	// Sidebar defines the sections and the order of the channels
+	Sidebar *config.Sidebar
 }
 
 // CreateChannels is the constructor for the Channels component
@@ -118,7 +171,7 @@ func CreateChannelsComponent(height int) *Channels {
 
 	channels.SelectedChannel = 0
 	channels.Offset = 0
-	channels.CursorPosition = channels.List.InnerBounds().Min.Y
+	channels.CursorPosition = channels.minY()
 
 	return channels
 }
@@ -127,14 +180,36 @@ func CreateChannelsComponent(height int) *Channels {
 func (c *Channels) Buffer() termui.Buffer {
 	buf := c.List.Buffer()
 
-	for i, item := range c.ChannelItems[c.Offset:] {
+	c.bufferUnreads(&buf)
 
-		y := c.List.InnerBounds().Min.Y + i
+	sections, rows := c.rows()
+	c.scroll(rows)
+
+	for i, row := range rows[c.Offset:] {
+
+		y := c.minY() + i
 
 		if y > c.List.InnerBounds().Max.Y-1 {
 			break
 		}
 
+		// The header of a section
+		if row.channel < 0 {
+			cells := termui.DefaultTxBuilder.Build(
+				sections[row.section].ToString(),
+				c.List.ItemFgColor, c.List.ItemBgColor)
+			cells = termui.DTrimTxCls(cells, c.List.InnerWidth())
+
+			x := c.List.InnerBounds().Min.X
+			for _, cell := range cells {
+				buf.Set(x, y, cell)
+				x += cell.Width()
+			}
+			continue
+		}
+
+		item := c.ChannelItems[row.channel]
+
 		// Set the visible cursor
 		var cells []termui.Cell
 		if y == c.CursorPosition {
@@ -181,6 +256,83 @@ func (c *Channels) Buffer() termui.Buffer {
 	return buf
 }
 
+// bufferUnreads will set the Unreads section at the top of the pane, it's
+// separated from the channels by a line
+func (c *Channels) bufferUnreads(buf *termui.Buffer) {
+	if len(c.UnreadItems) == 0 {
+		return
+	}
+
+	lines := []string{"[Unreads](fg-bold)"}
+	for _, item := range c.UnreadItems[:c.unreadsCount()] {
+		lines = append(lines, item.ToString())
+	}
+
+	y := c.List.InnerBounds().Min.Y
+	for _, line := range lines {
+		cells := termui.DefaultTxBuilder.Build(
+			line, c.List.ItemFgColor, c.List.ItemBgColor)
+		cells = termui.DTrimTxCls(cells, c.List.InnerWidth())
+
+		x := c.List.InnerBounds().Min.X
+		for _, cell := range cells {
+			buf.Set(x, y, cell)
+			x += cell.Width()
+		}
+		y++
+	}
+
+	for x := c.List.InnerBounds().Min.X; x < c.List.InnerBounds().Max.X; x++ {
+		buf.Set(x, y, termui.Cell{
+			Ch: '─',
+			Fg: c.List.BorderFg,
+			Bg: c.List.ItemBgColor,
+		})
+	}
+}
+
+// unreadsCount returns the amount of unread channels that are shown, the
+// Unreads section takes up at most half of the pane
+func (c *Channels) unreadsCount() int {
+	max := c.List.InnerHeight()/2 - 2
+	if max < 0 {
+		max = 0
+	}
+
+	if len(c.UnreadItems) < max {
+		return len(c.UnreadItems)
+	}
+	return max
+}
+
+// unreadsHeight returns the amount of lines the Unreads section takes up,
+// the header, the unread channels and the separator
+func (c *Channels) unreadsHeight() int {
+	if len(c.UnreadItems) == 0 {
+		return 0
+	}
+	return c.unreadsCount() + 2
+}
+
+// minY returns the y position of the first channel, below the Unreads
+// section
+func (c *Channels) minY() int {
+	return c.List.InnerBounds().Min.Y + c.unreadsHeight()
+}
+
+// SetUnreads will set the channels of the Unreads section, the cursor is
+// moved along when the section changes in size
+func (c *Channels) SetUnreads(items []UnreadItem) {
+	oldMinY := c.minY()
+	c.UnreadItems = items
+
+	if len(c.ChannelItems) > 0 {
+		c.GotoPosition(c.SelectedChannel)
+	} else {
+		c.CursorPosition += c.minY() - oldMinY
+	}
+}
+
 // GetHeight implements interface termui.GridBufferer
 func (c *Channels) GetHeight() int {
 	return c.List.Block.GetHeight()
@@ -205,29 +357,156 @@ func (c *Channels) SetChannels(channels []ChannelItem) {
 	c.ChannelItems = channels
 }
 
+// channelTypeOrder is the order in which the types of channels are shown
+var channelTypeOrder = map[string]int{
+	ChannelTypeChannel: 0,
+	ChannelTypeGroup:   1,
+	ChannelTypeMpIM:    2,
+	ChannelTypeIM:      3,
+}
+
+// AddChannel will add a channel to the channels, it is inserted amongst the
+// channels of the same type by its name. The index of the channel is
+// returned, the selected channel stays the same.
+func (c *Channels) AddChannel(item ChannelItem) int {
+	index := len(c.ChannelItems)
+	for i, channel := range c.ChannelItems {
+		if channelTypeOrder[channel.Type] > channelTypeOrder[item.Type] ||
+			channel.Type == item.Type && channel.Name > item.Name {
+			index = i
+			break
+		}
+	}
+
+	c.ChannelItems = append(c.ChannelItems, ChannelItem{})
+	copy(c.ChannelItems[index+1:], c.ChannelItems[index:])
+	c.ChannelItems[index] = item
+
+	if index <= c.SelectedChannel && len(c.ChannelItems) > 1 {
+		c.GotoPosition(c.SelectedChannel + 1)
+	}
+
+	return index
+}
+
+// RemoveChannel will remove a channel from the channels, the last channel
+// isn't removed. When it was the selected channel the next one is selected.
+func (c *Channels) RemoveChannel(channelID string) {
+	index := c.FindChannel(channelID)
+	if len(c.ChannelItems) < 2 || index < 0 {
+		return
+	}
+
+	c.ChannelItems = append(c.ChannelItems[:index], c.ChannelItems[index+1:]...)
+
+	selected := c.SelectedChannel
+	if index < selected || selected == len(c.ChannelItems) {
+		selected--
+	}
+
+	c.GotoPosition(selected)
+}
+
 func (c *Channels) MarkAsRead(channelID int) {
 	c.ChannelItems[channelID].Notification = false
+	c.ChannelItems[channelID].UnreadCount = 0
+	c.ChannelItems[channelID].MentionCount = 0
+}
+
+// SetBorderLabel will set the Label of the Channels pane
+func (c *Channels) SetBorderLabel(label string) {
+	c.List.BorderLabel = label
 }
 
 func (c *Channels) MarkAsUnread(channelID string) {
 	index := c.FindChannel(channelID)
+	if index < 0 {
+		return
+	}
+
+	c.ChannelItems[index].Notification = true
+}
+
+// AddUnread will count a new message in the channel, and mark it as unread
+func (c *Channels) AddUnread(channelID string, mention bool, latest time.Time) {
+	index := c.FindChannel(channelID)
+	if index < 0 {
+		return
+	}
+
 	c.ChannelItems[index].Notification = true
+	c.ChannelItems[index].UnreadCount++
+	if mention {
+		c.ChannelItems[index].MentionCount++
+	}
+	if latest.After(c.ChannelItems[index].Latest) {
+		c.ChannelItems[index].Latest = latest
+	}
+}
+
+// SetUnread will set the amount of unread messages of the channel, and the
+// time of its latest message
+func (c *Channels) SetUnread(channelID string, unread bool, count int, latest time.Time) {
+	index := c.FindChannel(channelID)
+	if index < 0 {
+		return
+	}
+
+	c.ChannelItems[index].Notification = unread
+	if unread {
+		c.ChannelItems[index].UnreadCount = count
+	} else {
+		c.ChannelItems[index].UnreadCount = 0
+		c.ChannelItems[index].MentionCount = 0
+	}
+	if latest.After(c.ChannelItems[index].Latest) {
+		c.ChannelItems[index].Latest = latest
+	}
+}
+
+// SetLatest will set the time of the latest message of the channel, when
+// it is more recent
+func (c *Channels) SetLatest(channelID string, latest time.Time) {
+	index := c.FindChannel(channelID)
+	if index < 0 {
+		return
+	}
+
+	if latest.After(c.ChannelItems[index].Latest) {
+		c.ChannelItems[index].Latest = latest
+	}
+}
+
+// SetNotificationPrefs will set whether the channel is muted, and its
+// notification level
+func (c *Channels) SetNotificationPrefs(channelID string, muted bool, level string) {
+	index := c.FindChannel(channelID)
+	if index < 0 {
+		return
+	}
+
+	c.ChannelItems[index].Muted = muted
+	c.ChannelItems[index].Level = level
 }
 
 func (c *Channels) SetPresence(channelID string, presence string) {
 	index := c.FindChannel(channelID)
+	if index < 0 {
+		return
+	}
+
 	c.ChannelItems[index].Presence = presence
 }
 
+// FindChannel returns the index of the channel with the identifier, and -1
+// when it isn't in the channels
 func (c *Channels) FindChannel(channelID string) int {
-	var index int
 	for i, channel := range c.ChannelItems {
 		if channel.ID == channelID {
-			index = i
-			break
+			return i
 		}
 	}
-	return index
+	return -1
 }
 
 // SetSelectedChannel sets the SelectedChannel given the index
@@ -235,71 +514,97 @@ func (c *Channels) SetSelectedChannel(index int) {
 	c.SelectedChannel = index
 }
 
+// GetChannel returns the ChannelItem with the identifier, ok is false when
+// it isn't in the channels
+func (c *Channels) GetChannel(channelID string) (item ChannelItem, ok bool) {
+	index := c.FindChannel(channelID)
+	if index < 0 {
+		return ChannelItem{}, false
+	}
+
+	return c.ChannelItems[index], true
+}
+
 // Get SelectedChannel returns the ChannelItem that is currently selected
 func (c *Channels) GetSelectedChannel() ChannelItem {
 	return c.ChannelItems[c.SelectedChannel]
 }
 
-// MoveCursorUp will decrease the SelectedChannel by 1
+// MoveCursorUp will select the channel above the selected channel
 func (c *Channels) MoveCursorUp() {
-	if c.SelectedChannel > 0 {
-		c.SetSelectedChannel(c.SelectedChannel - 1)
-		c.ScrollUp()
+	_, rows := c.rows()
+	for r := rowOf(rows, c.SelectedChannel) - 1; r >= 0; r-- {
+		if rows[r].channel >= 0 {
+			c.GotoPosition(rows[r].channel)
+			return
+		}
 	}
 }
 
-// MoveCursorDown will increase the SelectedChannel by 1
+// MoveCursorDown will select the channel below the selected channel
 func (c *Channels) MoveCursorDown() {
-	if c.SelectedChannel < len(c.ChannelItems)-1 {
-		c.SetSelectedChannel(c.SelectedChannel + 1)
-		c.ScrollDown()
+	_, rows := c.rows()
+	for r := rowOf(rows, c.SelectedChannel) + 1; r < len(rows); r++ {
+		if rows[r].channel >= 0 {
+			c.GotoPosition(rows[r].channel)
+			return
+		}
 	}
 }
 
 // MoveCursorTop will move the cursor to the top of the channels
 func (c *Channels) MoveCursorTop() {
-	c.SetSelectedChannel(0)
-	c.CursorPosition = c.List.InnerBounds().Min.Y
 	c.Offset = 0
+
+	_, rows := c.rows()
+	for _, row := range rows {
+		if row.channel >= 0 {
+			c.GotoPosition(row.channel)
+			return
+		}
+	}
 }
 
 // MoveCursorBottom will move the cursor to the bottom of the channels
 func (c *Channels) MoveCursorBottom() {
-	c.SetSelectedChannel(len(c.ChannelItems) - 1)
-
-	offset := len(c.ChannelItems) - (c.List.InnerBounds().Max.Y - 1)
-
-	if offset < 0 {
-		c.Offset = 0
-		c.CursorPosition = c.SelectedChannel + 1
-	} else {
-		c.Offset = offset
-		c.CursorPosition = c.List.InnerBounds().Max.Y - 1
+	_, rows := c.rows()
+	for r := len(rows) - 1; r >= 0; r-- {
+		if rows[r].channel >= 0 {
+			c.GotoPosition(rows[r].channel)
+			return
+		}
 	}
 }
 
-// ScrollUp enables us to scroll through the channel list when it overflows
-func (c *Channels) ScrollUp() {
-	// Is cursor at the top of the channel view?
-	if c.CursorPosition == c.List.InnerBounds().Min.Y {
-		if c.Offset > 0 {
+// scroll will set the Offset so that the selected channel is in view, and
+// the cursor on it. The rows are scrolled as little as possible.
+func (c *Channels) scroll(rows []channelRow) {
+	row := rowOf(rows, c.SelectedChannel)
+	if row < 0 {
+		row = 0
+	}
+
+	height := c.List.InnerBounds().Max.Y - c.minY()
+	if row < c.Offset {
+		c.Offset = row
+
+		// Show the header of the section above its first channel
+		if row > 0 && rows[row-1].channel < 0 {
 			c.Offset--
 		}
-	} else {
-		c.CursorPosition--
+	} else if row > c.Offset+height-1 {
+		c.Offset = row - height + 1
 	}
-}
 
-// ScrollDown enables us to scroll through the channel list when it overflows
-func (c *Channels) ScrollDown() {
-	// Is the cursor at the bottom of the channel view?
-	if c.CursorPosition == c.List.InnerBounds().Max.Y-1 {
-		if c.Offset < len(c.ChannelItems)-1 {
-			c.Offset++
-		}
-	} else {
-		c.CursorPosition++
+	// Don't leave empty lines at the bottom when scrolled down
+	if max := len(rows) - height; c.Offset > max {
+		c.Offset = max
 	}
+	if c.Offset < 0 {
+		c.Offset = 0
+	}
+
+	c.CursorPosition = (row - c.Offset) + c.minY()
 }
 
 // Search will search through the channels to find a channel,
@@ -333,30 +638,10 @@ func (c *Channels) Search(term string) {
 // GotoPosition is used by to automatically scroll to a specific
 // location in the channels component
 func (c *Channels) GotoPosition(newPos int) {
+	c.SetSelectedChannel(newPos)
 
-	// Is the new position in range of the current view?
-	minRange := c.Offset
-	maxRange := c.Offset + (c.List.InnerBounds().Max.Y - 2)
-
-	if newPos < minRange {
-		// newPos is above, we need to scroll up.
-		c.SetSelectedChannel(newPos)
-
-		// How much do we need to scroll to get it into range?
-		c.Offset = c.Offset - (minRange - newPos)
-	} else if newPos > maxRange {
-		// newPos is below, we need to scroll down
-		c.SetSelectedChannel(newPos)
-
-		// How much do we need to scroll to get it into range?
-		c.Offset = c.Offset + (newPos - maxRange)
-	} else {
-		// newPos is inside range
-		c.SetSelectedChannel(newPos)
-	}
-
-	// Set cursor to correct position
-	c.CursorPosition = (newPos - c.Offset) + 1
+	_, rows := c.rows()
+	c.scroll(rows)
 }
 
 // GotoPosition is used by the search functionality to automatically
@@ -386,10 +671,12 @@ func (c *Channels) SearchPrev() {
 
 // Jump to the first channel with a notification
 func (c *Channels) Jump() {
-	for i, channel := range c.ChannelItems {
-		if channel.Notification {
-			c.GotoPosition(i)
-			break
+	for _, section := range c.sections() {
+		for _, i := range section.channels {
+			if c.ChannelItems[i].Notification {
+				c.GotoPosition(i)
+				return
+			}
 		}
 	}
 }
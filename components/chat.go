@@ -2,9 +2,11 @@ package components
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/erroneousboat/termui"
 	runewidth "github.com/mattn/go-runewidth"
@@ -14,20 +16,57 @@ import (
 
 // Chat is the definition of a Chat component
 type Chat struct {
-	List     *termui.List
-	Messages map[string]Message
-	Offset   int
+	List            *termui.List
+	Messages        map[string]Message
+	Offset          int
+	SelectedMessage string // ID of the message that is highlighted
+	HasMore         bool   // Whether there are older messages to fetch
+	Thread          string // ID of the parent message when a thread is shown
+	ThreadCursor    string // Cursor of the next page of replies
+
+	// Highlights are the expressions that are highlighted in the text of
+	// the messages, with the StyleHighlight
+	Highlights     []*regexp.Regexp
+	StyleHighlight string
+
+	// lines is the amount of lines of the messages, as they were rendered
+	// by the last call to Buffer, and keepOffset will make Buffer keep
+	// the view in place when lines were added below it
+	lines      int
+	keepOffset bool
+
+	// channel is the state of the Chat pane before a thread was opened,
+	// so that it can be restored when the thread is closed
+	channel *chatState
+
+	// selection is the range of cells of the selected message, as set
+	// by MessagesToCells, and scrollToSelection will make Buffer adjust
+	// the Offset so that the selected message is in view
+	selection         [2]int
+	scrollToSelection bool
+}
+
+// chatState is the state of the Chat pane that is saved when a thread is
+// opened
+type chatState struct {
+	messages        map[string]Message
+	offset          int
+	selectedMessage string
+	hasMore         bool
+	lines           int
+	label           string
 }
 
 // CreateChatComponent is the constructor for the Chat struct
-func CreateChatComponent(inputHeight int) *Chat {
+func CreateChatComponent(height int) *Chat {
 	chat := &Chat{
 		List:     termui.NewList(),
 		Messages: make(map[string]Message),
 		Offset:   0,
+		HasMore:  true,
 	}
 
-	chat.List.Height = termui.TermHeight() - inputHeight
+	chat.List.Height = height
 	chat.List.Overflow = "wrap"
 
 	return chat
@@ -51,8 +90,18 @@ func (c *Chat) Buffer() termui.Buffer {
 
 	// When we encounter a newline or, are at the bounds of the chat view we
 	// stop iterating over the cells and add the line to the line array
+	// The first and last line of the selected message
+	selectionFirst, selectionLast := -1, -1
+
 	x := 0
-	for _, cell := range cells {
+	for i, cell := range cells {
+
+		if c.SelectedMessage != "" && i >= c.selection[0] && i < c.selection[1] {
+			if selectionFirst == -1 {
+				selectionFirst = len(lines)
+			}
+			selectionLast = len(lines)
+		}
 
 		// When we encounter a newline we add the line to the array
 		if cell.Ch == '\n' {
@@ -89,6 +138,43 @@ func (c *Chat) Buffer() termui.Buffer {
 	paneMinY := c.List.InnerBounds().Min.Y
 	paneMaxY := c.List.InnerBounds().Max.Y
 
+	// When lines were added below the messages that are in view, increase
+	// the Offset with the amount of lines so that the view stays in place
+	if c.keepOffset {
+		c.Offset += linesHeight - c.lines
+		c.keepOffset = false
+	}
+
+	// Protect overscrolling, the last line should stay at the bottom
+	if c.Offset < 0 {
+		c.Offset = 0
+	}
+
+	// Scroll the selected message into view, when it is below the pane
+	// we align it with the bottom, and when it is above the pane we align
+	// it with the top.
+	if c.scrollToSelection && selectionFirst != -1 {
+		bottom := (linesHeight - 1) - c.Offset
+		top := bottom - (paneMaxY - paneMinY) + 1
+
+		if selectionLast > bottom {
+			c.Offset = (linesHeight - 1) - selectionLast
+		} else if selectionFirst < top {
+			c.Offset = (linesHeight - 1) - selectionFirst - (paneMaxY - paneMinY) + 1
+		}
+
+		if c.Offset < 0 {
+			c.Offset = 0
+		}
+	}
+	c.scrollToSelection = false
+
+	// Protect overscrolling, the first line should stay at the top
+	c.lines = linesHeight
+	if c.Offset > c.getMaxOffset() {
+		c.Offset = c.getMaxOffset()
+	}
+
 	currentY := paneMaxY - 1
 	for i := (linesHeight - 1) - c.Offset; i >= 0; i-- {
 
@@ -166,22 +252,133 @@ func (c *Chat) GetMaxItems() int {
 	return c.List.InnerBounds().Max.Y - c.List.InnerBounds().Min.Y
 }
 
-// SetMessages will put the provided messages into the Messages field of the
-// Chat view
+// SetMessages will replace the Messages of the Chat view with the provided
+// messages, including the replies that were added to them
 func (c *Chat) SetMessages(messages []Message) {
 	// Reset offset first, when scrolling in view and changing channels we
 	// want the offset to be 0 when loading new messages
 	c.Offset = 0
+	c.Messages = make(map[string]Message)
+	for _, msg := range messages {
+		c.Messages[msg.ID] = msg
+	}
+}
+
+// AddOlderMessages adds messages that were sent before the messages that
+// are already in the Chat pane. Because the Offset is counted from the
+// bottom, the messages that are in view stay in place.
+func (c *Chat) AddOlderMessages(messages []Message, hasMore bool) {
 	for _, msg := range messages {
 		c.Messages[msg.ID] = msg
 	}
+
+	c.HasMore = hasMore
+}
+
+// GetOldestMessageID returns the ID of the oldest message in the Chat pane,
+// it is empty when there are no messages
+func (c *Chat) GetOldestMessageID() string {
+	for _, msg := range SortMessages(c.Messages) {
+		if !msg.Time.IsZero() {
+			return msg.ID
+		}
+	}
+	return ""
+}
+
+// GetNewestMessageID returns the ID of the newest message in the Chat pane,
+// it is empty when there are no messages
+func (c *Chat) GetNewestMessageID() string {
+	msgs := SortMessages(c.Messages)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if !msgs[i].Time.IsZero() {
+			return msgs[i].ID
+		}
+	}
+	return ""
 }
 
-// AddMessage adds a single message to Messages
+// AddMessage adds a single message to Messages, when the message is already
+// present its replies are kept
 func (c *Chat) AddMessage(message Message) {
+	if old, ok := c.Messages[message.ID]; ok {
+		for id, reply := range old.Messages {
+			if _, ok := message.Messages[id]; !ok {
+				message.Messages[id] = reply
+			}
+		}
+	}
+
 	c.Messages[message.ID] = message
 }
 
+// OpenThread will show the parent message and the replies of a thread in
+// the Chat pane. When a channel was shown, its messages and position are
+// saved so they can be restored by CloseThread.
+func (c *Chat) OpenThread(parent Message, replies []Message, cursor string) {
+	if c.Thread == "" {
+		c.channel = &chatState{
+			messages:        c.Messages,
+			offset:          c.Offset,
+			selectedMessage: c.SelectedMessage,
+			hasMore:         c.HasMore,
+			lines:           c.lines,
+			label:           c.List.BorderLabel,
+		}
+	}
+
+	if parent.Messages == nil {
+		parent.Messages = make(map[string]Message)
+	}
+
+	c.Messages = map[string]Message{parent.ID: parent}
+	c.Offset = 0
+	c.SelectedMessage = ""
+	c.HasMore = false
+	c.Thread = parent.ID
+	c.ThreadCursor = ""
+
+	c.AddReplies(replies, cursor)
+	c.keepOffset = false
+}
+
+// AddReplies adds a page of replies to the thread that is shown, the
+// replies are added below the messages that are in view, so the view
+// stays in place
+func (c *Chat) AddReplies(replies []Message, cursor string) {
+	for _, reply := range replies {
+		c.AddReply(c.Thread, reply)
+	}
+
+	c.ThreadCursor = cursor
+	c.keepOffset = true
+}
+
+// CloseThread will restore the messages and the position of the channel
+// that was shown before the thread was opened
+func (c *Chat) CloseThread() {
+	if c.Thread == "" {
+		return
+	}
+
+	c.Thread = ""
+	c.ThreadCursor = ""
+	c.keepOffset = false
+
+	if c.channel == nil {
+		c.ClearMessages()
+		return
+	}
+
+	c.Messages = c.channel.messages
+	c.Offset = c.channel.offset
+	c.SelectedMessage = c.channel.selectedMessage
+	c.HasMore = c.channel.hasMore
+	c.lines = c.channel.lines
+	c.List.BorderLabel = c.channel.label
+	c.channel = nil
+}
+
 // AddReply adds a single reply to a parent thread, it also sets
 // the thread separator
 func (c *Chat) AddReply(parentID string, message Message) {
@@ -195,6 +392,105 @@ func (c *Chat) AddReply(parentID string, message Message) {
 	}
 }
 
+// DeleteMessage removes a single message, it can either be a message in
+// the channel or a reply in one of its threads
+func (c *Chat) DeleteMessage(messageID string) {
+	deleteMessage(c.Messages, messageID)
+
+	if c.SelectedMessage == messageID {
+		c.SelectedMessage = ""
+	}
+}
+
+func deleteMessage(msgs map[string]Message, messageID string) {
+	if _, ok := msgs[messageID]; ok {
+		delete(msgs, messageID)
+		return
+	}
+
+	for _, msg := range msgs {
+		deleteMessage(msg.Messages, messageID)
+	}
+}
+
+// AddReaction will add the user to the reaction on the message, when the
+// message doesn't have the reaction yet it is added
+func (c *Chat) AddReaction(messageID string, reaction Reaction, userID string) {
+	updateMessage(c.Messages, messageID, func(msg *Message) {
+		for i, r := range msg.Reactions {
+			if r.Name != reaction.Name {
+				continue
+			}
+
+			if !r.HasUser(userID) {
+				msg.Reactions[i].Count++
+				msg.Reactions[i].Users = append(r.Users, userID)
+			}
+			return
+		}
+
+		reaction.Count = 1
+		reaction.Users = []string{userID}
+		msg.Reactions = append(msg.Reactions, reaction)
+	})
+}
+
+// RemoveReaction will remove the user from the reaction on the message,
+// when no users are left the reaction is removed
+func (c *Chat) RemoveReaction(messageID string, name string, userID string) {
+	updateMessage(c.Messages, messageID, func(msg *Message) {
+		reactions := make([]Reaction, 0)
+		for _, r := range msg.Reactions {
+			if r.Name == name && r.HasUser(userID) {
+				users := make([]string, 0)
+				for _, user := range r.Users {
+					if user != userID {
+						users = append(users, user)
+					}
+				}
+
+				r.Users = users
+				r.Count--
+			}
+
+			if r.Count > 0 {
+				reactions = append(reactions, r)
+			}
+		}
+
+		msg.Reactions = reactions
+	})
+}
+
+// GetMessage returns the message with the given ID, it can either be a
+// message in the channel or a reply in one of its threads
+func (c *Chat) GetMessage(messageID string) (Message, bool) {
+	for _, msg := range c.GetMessages() {
+		if msg.ID == messageID {
+			return msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// updateMessage will apply fn to the message with the given ID, and store
+// the result
+func updateMessage(msgs map[string]Message, messageID string, fn func(*Message)) bool {
+	if msg, ok := msgs[messageID]; ok {
+		fn(&msg)
+		msgs[messageID] = msg
+		return true
+	}
+
+	for _, msg := range msgs {
+		if updateMessage(msg.Messages, messageID, fn) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsNewThread check whether a message that is going to be added as
 // a child to a parent message, is the first one or not
 func (c *Chat) IsNewThread(parentID string) bool {
@@ -209,6 +505,116 @@ func (c *Chat) IsNewThread(parentID string) bool {
 // ClearMessages clear the c.Messages
 func (c *Chat) ClearMessages() {
 	c.Messages = make(map[string]Message)
+	c.SelectedMessage = ""
+	c.HasMore = true
+	c.Thread = ""
+	c.ThreadCursor = ""
+	c.channel = nil
+}
+
+// GetMessages returns the messages, including the thread replies, in the
+// order in which they're rendered in the Chat pane
+func (c *Chat) GetMessages() []Message {
+	return flattenMessages(c.Messages)
+}
+
+// GetMessagesFromUser returns the messages, including the thread replies,
+// that were sent by the user, in the order in which they're rendered in
+// the Chat pane
+func (c *Chat) GetMessagesFromUser(userID string) []Message {
+	var msgs []Message
+	for _, msg := range c.GetMessages() {
+		if msg.UserID == userID {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// SetSelectedMessage will highlight the message with the given ID, an
+// empty ID will remove the highlight
+func (c *Chat) SetSelectedMessage(messageID string) {
+	c.SelectedMessage = messageID
+	c.scrollToSelection = messageID != ""
+}
+
+// GetSelectedMessage returns the message that is highlighted, it returns
+// false when no message is selected
+func (c *Chat) GetSelectedMessage() (Message, bool) {
+	if c.SelectedMessage == "" {
+		return Message{}, false
+	}
+
+	return c.GetMessage(c.SelectedMessage)
+}
+
+// SelectPrevMessage will move the selection to the message above the
+// selected message. When no message is selected, the last message will be
+// selected.
+func (c *Chat) SelectPrevMessage() {
+	msgs := c.getSelectableMessages()
+	if len(msgs) == 0 {
+		return
+	}
+
+	if c.SelectedMessage == "" {
+		c.SetSelectedMessage(msgs[len(msgs)-1].ID)
+		return
+	}
+
+	for i, msg := range msgs {
+		if msg.ID == c.SelectedMessage && i > 0 {
+			c.SetSelectedMessage(msgs[i-1].ID)
+			return
+		}
+	}
+}
+
+// SelectNextMessage will move the selection to the message below the
+// selected message. Moving past the last message removes the selection
+// and scrolls down.
+func (c *Chat) SelectNextMessage() {
+	if c.SelectedMessage == "" {
+		return
+	}
+
+	msgs := c.getSelectableMessages()
+	for i, msg := range msgs {
+		if msg.ID == c.SelectedMessage {
+			if i < len(msgs)-1 {
+				c.SetSelectedMessage(msgs[i+1].ID)
+				return
+			}
+			break
+		}
+	}
+
+	c.SetSelectedMessage("")
+	c.Offset = 0
+}
+
+// getSelectableMessages returns the messages that can be selected, this
+// excludes attachments and help messages because they don't have a time
+// and aren't Selectable
+func (c *Chat) getSelectableMessages() []Message {
+	var msgs []Message
+	for _, msg := range c.GetMessages() {
+		if !msg.Time.IsZero() || msg.Selectable {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// flattenMessages returns the messages and their replies as rendered by
+// MessagesToCells
+func flattenMessages(msgs map[string]Message) []Message {
+	var flattened []Message
+	for _, msg := range SortMessages(msgs) {
+		flattened = append(flattened, msg)
+		flattened = append(flattened, flattenMessages(msg.Messages)...)
+	}
+	return flattened
 }
 
 // ScrollUp will render the chat messages based on the Offset of the Chat
@@ -218,13 +624,27 @@ func (c *Chat) ClearMessages() {
 // start with rendering last item in the list at the maximum y of the Chat
 // pane). Increasing the Offset will thus result in substracting the offset
 // from the len(Chat.Messages).
+//
+// Overscrolling is prevented by Buffer, because the amount of lines is only
+// known when the messages are rendered.
 func (c *Chat) ScrollUp() {
 	c.Offset = c.Offset + 10
+}
+
+// IsScrolledToTop returns whether the first line of the messages is in
+// view
+func (c *Chat) IsScrolledToTop() bool {
+	return c.Offset >= c.getMaxOffset()
+}
 
-	// Protect overscrolling
-	if c.Offset > len(c.Messages) {
-		c.Offset = len(c.Messages)
+// getMaxOffset returns the Offset at which the first line of the messages
+// is at the top of the Chat pane
+func (c *Chat) getMaxOffset() int {
+	maxOffset := c.lines - c.GetMaxItems()
+	if maxOffset < 0 {
+		return 0
 	}
+	return maxOffset
 }
 
 // ScrollDown will render the chat messages based on the Offset of the Chat
@@ -234,13 +654,16 @@ func (c *Chat) ScrollUp() {
 // start with rendering last item in the list at the maximum y of the Chat
 // pane). Increasing the Offset will thus result in substracting the offset
 // from the len(Chat.Messages).
+//
+// Overscrolling is prevented by Buffer, for the same reason as ScrollUp.
 func (c *Chat) ScrollDown() {
 	c.Offset = c.Offset - 10
+}
 
-	// Protect overscrolling
-	if c.Offset < 0 {
-		c.Offset = 0
-	}
+// IsScrolledToBottom returns whether the last line of the messages is in
+// view
+func (c *Chat) IsScrolledToBottom() bool {
+	return c.Offset <= 0
 }
 
 // SetBorderLabel will set Label of the Chat pane to the specified string
@@ -251,15 +674,33 @@ func (c *Chat) SetBorderLabel(channelName string) {
 // MessagesToCells is a wrapper around MessageToCells to use for a slice of
 // of type Message
 func (c *Chat) MessagesToCells(msgs map[string]Message) []termui.Cell {
-	cells := make([]termui.Cell, 0)
+	c.selection = [2]int{}
+	return c.messagesToCells(msgs, make([]termui.Cell, 0))
+}
+
+// messagesToCells appends the cells of the messages to cells, this way the
+// range of the selected message can be set as indexes into the cells
+func (c *Chat) messagesToCells(msgs map[string]Message, cells []termui.Cell) []termui.Cell {
 	sortedMessages := SortMessages(msgs)
 
 	for i, msg := range sortedMessages {
-		cells = append(cells, c.MessageToCells(msg)...)
+		msgCells := c.MessageToCells(msg)
+
+		// Reverse the colors of the selected message
+		if msg.ID != "" && msg.ID == c.SelectedMessage {
+			for j := range msgCells {
+				msgCells[j].Fg = c.List.ItemBgColor
+				msgCells[j].Bg = c.List.ItemFgColor
+			}
+
+			c.selection = [2]int{len(cells), len(cells) + len(msgCells)}
+		}
+
+		cells = append(cells, msgCells...)
 
 		if len(msg.Messages) > 0 {
 			cells = append(cells, termui.Cell{Ch: '\n'})
-			cells = append(cells, c.MessagesToCells(msg.Messages)...)
+			cells = c.messagesToCells(msg.Messages, cells)
 		}
 
 		// Add a newline after every message
@@ -307,21 +748,86 @@ func (c *Chat) MessageToCells(msg Message) []termui.Cell {
 		termui.ColorDefault, termui.ColorDefault,
 	)
 
-	// Text
+	// Text, with the highlights in their own style
+	hlCells := termui.DefaultTxBuilder.Build(
+		fmt.Sprintf("[.](%s)", c.StyleHighlight),
+		termui.ColorDefault, termui.ColorDefault,
+	)
+	highlighted := c.highlights(msg.Content)
+
+	i := 0
 	for _, r := range msg.Content {
-		cells = append(
-			cells,
-			termui.Cell{
-				Ch: r,
-				Fg: txCells[0].Fg,
-				Bg: txCells[0].Bg,
-			},
+		cell := termui.Cell{
+			Ch: r,
+			Fg: txCells[0].Fg,
+			Bg: txCells[0].Bg,
+		}
+		if highlighted[i] {
+			cell.Fg = hlCells[0].Fg
+			cell.Bg = hlCells[0].Bg
+		}
+		cells = append(cells, cell)
+		i++
+	}
+
+	// Edited
+	if msg.Edited {
+		cells = append(cells, termui.DefaultTxBuilder.Build(
+			" (edited)",
+			termui.ColorDefault, termui.ColorDefault)...,
 		)
 	}
 
+	// Replies, when they aren't shown yet the amount is shown on its own
+	// line underneath the message
+	if msg.ReplyCount > 0 && !msg.HasReplies() {
+		cells = append(cells, termui.Cell{Ch: '\n'})
+		cells = append(cells, termui.DefaultTxBuilder.Build(
+			fmt.Sprintf("    [%s](%s)", msg.GetReplies(), msg.StyleThread),
+			termui.ColorDefault, termui.ColorDefault)...,
+		)
+	}
+
+	// Reactions, on their own line underneath the message. These are
+	// added as plain cells, for the same reason as the text.
+	if len(msg.Reactions) > 0 {
+		cells = append(cells, termui.Cell{Ch: '\n'})
+		for _, r := range "    " + msg.GetReactions() {
+			cells = append(
+				cells,
+				termui.Cell{
+					Ch: r,
+					Fg: txCells[0].Fg,
+					Bg: txCells[0].Bg,
+				},
+			)
+		}
+	}
+
 	return cells
 }
 
+// highlights returns for every rune of the text whether it matches one of
+// the Highlights
+func (c *Chat) highlights(text string) []bool {
+	highlighted := make([]bool, utf8.RuneCountInString(text))
+	if c.StyleHighlight == "" {
+		return highlighted
+	}
+
+	for _, r := range c.Highlights {
+		for _, loc := range r.FindAllStringIndex(text, -1) {
+			i := utf8.RuneCountInString(text[:loc[0]])
+			for range text[loc[0]:loc[1]] {
+				highlighted[i] = true
+				i++
+			}
+		}
+	}
+
+	return highlighted
+}
+
 // Help shows the usage and key bindings in the chat pane
 func (c *Chat) Help(usage string, cfg *config.Config) {
 	msgUsage := Message{
@@ -13,13 +13,13 @@ type Debug struct {
 	List *termui.List
 }
 
-func CreateDebugComponent(inputHeight int) *Debug {
+func CreateDebugComponent(height int) *Debug {
 	debug := &Debug{
 		List: termui.NewList(),
 	}
 
 	debug.List.BorderLabel = "Debug"
-	debug.List.Height = termui.TermHeight() - inputHeight
+	debug.List.Height = height
 	debug.List.Overflow = "wrap"
 
 	return debug
@@ -12,6 +12,11 @@ type Input struct {
 	CursorPositionScreen int
 	CursorPositionText   int
 	Offset               int
+
+	// Status is shown on the right side of the top border, it is used to
+	// relay errors and the state of the application
+	Status      string
+	StatusError bool
 }
 
 // CreateInput is the constructor of the Input struct
@@ -46,6 +51,28 @@ func (i *Input) Buffer() termui.Buffer {
 		},
 	)
 
+	// Set the status on the top border, aligned to the right
+	if i.Status != "" {
+		fg := i.Par.BorderLabelFg
+		if i.StatusError {
+			fg = termui.ColorRed | termui.AttrBold
+		}
+
+		status := []rune(" " + i.Status + " ")
+
+		// Truncate the status when it doesn't fit
+		maxWidth := i.Par.InnerBounds().Dx() - 2
+		for runewidth.StringWidth(string(status)) > maxWidth && len(status) > 0 {
+			status = status[:len(status)-1]
+		}
+
+		x := i.Par.InnerBounds().Max.X - runewidth.StringWidth(string(status)) - 1
+		for _, r := range status {
+			buf.Set(x, i.Par.Y, termui.Cell{Ch: r, Fg: fg, Bg: i.Par.BorderLabelBg})
+			x += runewidth.RuneWidth(r)
+		}
+	}
+
 	return buf
 }
 
@@ -208,6 +235,15 @@ func (i *Input) Clear() {
 	i.Offset = 0
 }
 
+// SetText will replace the text of the input and move the cursor to the
+// end of it
+func (i *Input) SetText(text string) {
+	i.Clear()
+	for _, r := range text {
+		i.Insert(r)
+	}
+}
+
 // GetText returns the text currently in the input
 func (i *Input) GetText() string {
 	return string(i.Text)
@@ -218,3 +254,16 @@ func (i *Input) GetText() string {
 func (i *Input) GetMaxWidth() int {
 	return i.Par.InnerBounds().Dx() - 1
 }
+
+// SetBorderLabel will set Label of the Input component to the specified
+// string
+func (i *Input) SetBorderLabel(label string) {
+	i.Par.BorderLabel = label
+}
+
+// SetStatus will show the status on the border of the Input component, an
+// empty status will remove it
+func (i *Input) SetStatus(status string, isError bool) {
+	i.Status = status
+	i.StatusError = isError
+}
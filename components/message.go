@@ -24,10 +24,29 @@ type Message struct {
 	ID       string
 	Messages map[string]Message
 
-	Time    time.Time
-	Thread  string
-	Name    string
-	Content string
+	Time     time.Time
+	Thread   string
+	ThreadID string
+	UserID   string
+	Name     string
+	Content  string
+	Edited   bool
+
+	// Text is the text of the message as slack formats it, Content is how
+	// it is shown. The Text is edited, so that the links and mentions in
+	// it are kept.
+	Text string
+
+	Reactions []Reaction
+
+	// ReplyCount and LatestReply are shown underneath a parent message,
+	// as long as its replies aren't present in Messages
+	ReplyCount  int
+	LatestReply time.Time
+
+	// Selectable makes a message without a time selectable, like the
+	// users and the channels that are listed in the Chat pane
+	Selectable bool
 
 	StyleTime   string
 	StyleThread string
@@ -37,6 +56,25 @@ type Message struct {
 	FormatTime string
 }
 
+// Reaction is the definition of an emoji reaction on a message, Icon is
+// how the reaction is displayed
+type Reaction struct {
+	Name  string
+	Icon  string
+	Count int
+	Users []string
+}
+
+// HasUser returns whether the user is one of the users that reacted
+func (r Reaction) HasUser(userID string) bool {
+	for _, user := range r.Users {
+		if user == userID {
+			return true
+		}
+	}
+	return false
+}
+
 func (m Message) GetTime() string {
 	return fmt.Sprintf(
 		"[[%s]](%s) ",
@@ -63,6 +101,55 @@ func (m Message) GetContent() string {
 	return fmt.Sprintf("[.](%s)", m.StyleText)
 }
 
+// GetReactions returns the reactions with their counts, as they're shown
+// underneath the message
+func (m Message) GetReactions() string {
+	reactions := make([]string, 0)
+	for _, r := range m.Reactions {
+		reactions = append(reactions, fmt.Sprintf("%s %d", r.Icon, r.Count))
+	}
+
+	return strings.Join(reactions, "  ")
+}
+
+// GetReplies returns the amount of replies of a parent message, as it is
+// shown underneath the message
+func (m Message) GetReplies() string {
+	replies := fmt.Sprintf("%d replies", m.ReplyCount)
+	if m.ReplyCount == 1 {
+		replies = "1 reply"
+	}
+
+	if !m.LatestReply.IsZero() {
+		replies = fmt.Sprintf(
+			"%s, last reply at %s", replies, m.LatestReply.Format(m.FormatTime),
+		)
+	}
+
+	return replies
+}
+
+// HasReplies returns whether the replies of a parent message are present in
+// Messages
+func (m Message) HasReplies() bool {
+	for _, msg := range m.Messages {
+		if msg.ThreadID == m.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReaction returns the reaction with the given name
+func (m Message) GetReaction(name string) (Reaction, bool) {
+	for _, r := range m.Reactions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Reaction{}, false
+}
+
 func (m Message) colorizeName(styleName string) string {
 	if strings.Contains(styleName, "colorize") {
 		var sum int
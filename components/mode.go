@@ -8,11 +8,25 @@ const (
 	CommandMode = "NORMAL"
 	InsertMode  = "INSERT"
 	SearchMode  = "SEARCH"
+	EditMode    = "EDIT"
+
+	SearchMessagesMode = "SEARCH MSG"
+	SearchUsersMode    = "SEARCH USR"
+)
+
+const (
+	ConnectionConnected  = ""
+	ConnectionConnecting = "CONNECTING"
+	ConnectionOffline    = "OFFLINE"
 )
 
 // Mode is the definition of Mode component
 type Mode struct {
 	Par *termui.Par
+
+	// Connection is the state of the connection with slack, it is shown
+	// on the border when we aren't connected
+	Connection string
 }
 
 // CreateMode is the constructor of the Mode struct
@@ -67,6 +81,23 @@ func (m *Mode) Buffer() termui.Buffer {
 		}
 	}
 
+	// Set the connection state on the top border
+	if m.Connection != ConnectionConnected {
+		fg := termui.ColorYellow | termui.AttrBold
+		if m.Connection == ConnectionOffline {
+			fg = termui.ColorRed | termui.AttrBold
+		}
+
+		x := m.Par.InnerBounds().Min.X
+		for _, r := range m.Connection {
+			if x >= m.Par.InnerBounds().Max.X {
+				break
+			}
+			buf.Set(x, m.Par.Y, termui.Cell{Ch: r, Fg: fg, Bg: m.Par.BorderLabelBg})
+			x++
+		}
+	}
+
 	return buf
 }
 
@@ -92,15 +123,34 @@ func (m *Mode) SetY(y int) {
 
 func (m *Mode) SetInsertMode() {
 	m.Par.Text = InsertMode
-	termui.Render(m)
 }
 
 func (m *Mode) SetCommandMode() {
 	m.Par.Text = CommandMode
-	termui.Render(m)
 }
 
 func (m *Mode) SetSearchMode() {
 	m.Par.Text = SearchMode
-	termui.Render(m)
+}
+
+func (m *Mode) SetSearchMessagesMode() {
+	m.Par.Text = SearchMessagesMode
+}
+
+func (m *Mode) SetSearchUsersMode() {
+	m.Par.Text = SearchUsersMode
+}
+
+func (m *Mode) SetEditMode() {
+	m.Par.Text = EditMode
+}
+
+// SetConfirmMode will show the prompt to which the user needs to answer
+func (m *Mode) SetConfirmMode(prompt string) {
+	m.Par.Text = prompt
+}
+
+// SetConnection will set the state of the connection with slack
+func (m *Mode) SetConnection(state string) {
+	m.Connection = state
 }
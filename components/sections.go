@@ -0,0 +1,193 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/erroneousboat/slack-term/config"
+)
+
+const (
+	IconExpanded  = "▾"
+	IconCollapsed = "▸"
+
+	SectionFavourites     = "Favourites"
+	SectionChannels       = "Channels"
+	SectionDirectMessages = "Direct messages"
+)
+
+// channelSection is a section of the Channels pane, channels are the
+// indexes of its channels in the order they're shown
+type channelSection struct {
+	name      string
+	collapsed bool
+	channels  []int
+}
+
+// ToString will set the label of the header of the section
+func (s channelSection) ToString() string {
+	icon := IconExpanded
+	if s.collapsed {
+		icon = IconCollapsed
+	}
+	return fmt.Sprintf("[%s %s](fg-bold)", icon, s.name)
+}
+
+// channelRow is a line of the channels below the Unreads section, it is the
+// header of a section when channel is -1
+type channelRow struct {
+	section int
+	channel int
+}
+
+// sections returns the sections of the channels that have any channels in
+// them. Without favourites and sections in the Sidebar all channels are in
+// one section without a header.
+func (c *Channels) sections() []channelSection {
+	if c.Sidebar == nil || !c.Sidebar.HasSections() {
+		all := make([]int, len(c.ChannelItems))
+		for i := range all {
+			all[i] = i
+		}
+		return []channelSection{{channels: c.sortChannels(all)}}
+	}
+
+	favourites := channelSection{name: SectionFavourites}
+	custom := make([]channelSection, len(c.Sidebar.Sections))
+	channels := channelSection{name: SectionChannels}
+	ims := channelSection{name: SectionDirectMessages}
+
+	// The position of a channel in the config, the favourites and the
+	// sections keep that order
+	order := make(map[int]int)
+
+CHANNELS:
+	for i, item := range c.ChannelItems {
+		if pos := config.MatchChannel(c.Sidebar.Favourites, item.ID, item.Name); pos >= 0 {
+			favourites.channels = append(favourites.channels, i)
+			order[i] = pos
+			continue
+		}
+
+		for s, section := range c.Sidebar.Sections {
+			if pos := config.MatchChannel(section.Channels, item.ID, item.Name); pos >= 0 {
+				custom[s].channels = append(custom[s].channels, i)
+				order[i] = pos
+				continue CHANNELS
+			}
+		}
+
+		if item.Type == ChannelTypeIM || item.Type == ChannelTypeMpIM {
+			ims.channels = append(ims.channels, i)
+		} else {
+			channels.channels = append(channels.channels, i)
+		}
+	}
+
+	sections := make([]channelSection, 0)
+	if len(favourites.channels) > 0 {
+		sections = append(sections, favourites)
+	}
+	for s, section := range c.Sidebar.Sections {
+		// A section without any of the channels isn't shown
+		if len(custom[s].channels) == 0 {
+			continue
+		}
+
+		custom[s].name = section.Name
+		sections = append(sections, custom[s])
+	}
+	if len(channels.channels) > 0 {
+		sections = append(sections, channels)
+	}
+	if len(ims.channels) > 0 {
+		sections = append(sections, ims)
+	}
+
+	for s := range sections {
+		indexes := sections[s].channels
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return order[indexes[i]] < order[indexes[j]]
+		})
+
+		sections[s].channels = c.sortChannels(indexes)
+		sections[s].collapsed = c.Sidebar.IsCollapsed(sections[s].name)
+	}
+
+	return sections
+}
+
+// sortChannels will sort the channels with the most recent activity first
+// when the Sidebar is set to do so, otherwise they're left as they are
+func (c *Channels) sortChannels(indexes []int) []int {
+	if c.Sidebar == nil || c.Sidebar.Sort != config.SortRecent {
+		return indexes
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return c.ChannelItems[indexes[i]].Latest.After(
+			c.ChannelItems[indexes[j]].Latest)
+	})
+	return indexes
+}
+
+// rows returns the sections and the lines of the channels as they're shown,
+// the channels that are hidden are left out
+func (c *Channels) rows() ([]channelSection, []channelRow) {
+	sections := c.sections()
+
+	rows := make([]channelRow, 0)
+	for s, section := range sections {
+		if section.name != "" {
+			rows = append(rows, channelRow{section: s, channel: -1})
+		}
+
+		for _, i := range section.channels {
+			if i != c.SelectedChannel && c.isHidden(section, i) {
+				continue
+			}
+			rows = append(rows, channelRow{section: s, channel: i})
+		}
+	}
+
+	return sections, rows
+}
+
+// isHidden returns whether the channel is hidden, which is the case for the
+// channels that are muted, in slack or in the config, when they're hidden,
+// and for the channels of a collapsed section without unread messages
+func (c *Channels) isHidden(section channelSection, index int) bool {
+	if c.Sidebar == nil {
+		return false
+	}
+
+	item := c.ChannelItems[index]
+	if c.Sidebar.HideMuted && (item.Muted || c.Sidebar.IsMuted(item.ID, item.Name)) {
+		return true
+	}
+
+	return section.collapsed && !item.Notification
+}
+
+// rowOf returns the row of the channel, and -1 when it isn't shown
+func rowOf(rows []channelRow, index int) int {
+	for r, row := range rows {
+		if row.channel == index {
+			return r
+		}
+	}
+	return -1
+}
+
+// GetSelectedSection returns the name of the section of the selected
+// channel, it is empty when the channels aren't divided into sections
+func (c *Channels) GetSelectedSection() string {
+	for _, section := range c.sections() {
+		for _, i := range section.channels {
+			if i == c.SelectedChannel {
+				return section.name
+			}
+		}
+	}
+	return ""
+}
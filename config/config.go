@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	fp "path/filepath"
+	"regexp"
 
 	"github.com/OpenPeeDeeP/xdg"
 	"github.com/erroneousboat/termui"
@@ -17,16 +18,83 @@ const (
 	NotifyMention = "mention"
 )
 
+// The notifiers that deliver the notifications
+const (
+	NotifierDesktop = "desktop"
+	NotifierBell    = "bell"
+	NotifierOSC9    = "osc9"
+	NotifierOSC777  = "osc777"
+	NotifierCommand = "command"
+)
+
+// The notification levels of a channel, they define whether the bell,
+// the desktop notification and the unread marker are shown for every
+// message, only for mentions, or not at all
+const (
+	LevelAll      = "all"
+	LevelMentions = "mentions"
+	LevelNothing  = "nothing"
+)
+
+// The events on which the hooks are run, the filter hooks are run on the
+// outgoing messages
+const (
+	HookMessageReceived = "message_received"
+	HookMention         = "mention"
+	HookMessageSent     = "message_sent"
+	HookChannelChanged  = "channel_changed"
+	HookMessageFilter   = "message_filter"
+)
+
+const (
+	EventSourceRTM        = "rtm"
+	EventSourceSocketMode = "socket_mode"
+)
+
 // Config is the definition of a Config struct
 type Config struct {
-	SlackToken   string                `json:"slack_token"`
-	Notify       string                `json:"notify"`
-	Emoji        bool                  `json:"emoji"`
-	SidebarWidth int                   `json:"sidebar_width"`
-	MainWidth    int                   `json:"-"`
-	ThreadsWidth int                   `json:"threads_width"`
-	KeyMap       map[string]keyMapping `json:"key_map"`
-	Theme        Theme                 `json:"theme"`
+	SlackToken    string                `json:"slack_token"`
+	APIURL        string                `json:"api_url"`
+	AppToken      string                `json:"app_token"`
+	EventSource   string                `json:"event_source"`
+	Notify        string                `json:"notify"`
+	Notifier      string                `json:"notifier"`
+	NotifyCommand string                `json:"notify_command"`
+	Bell          bool                  `json:"bell"`
+	Emoji         bool                  `json:"emoji"`
+	Unreads       bool                  `json:"unreads"`
+	Cache         bool                  `json:"cache"`
+	Levels        map[string]string     `json:"notifications"`
+	Highlights    []string              `json:"highlights"`
+	Hooks         map[string][]string   `json:"hooks"`
+	SidebarWidth  int                   `json:"sidebar_width"`
+	MainWidth     int                   `json:"-"`
+	ThreadsWidth  int                   `json:"threads_width"`
+	KeyMap        map[string]keyMapping `json:"key_map"`
+	Theme         Theme                 `json:"theme"`
+	Sidebar       Sidebar               `json:"sidebar"`
+	Workspaces    []Workspace           `json:"workspaces"`
+
+	// SidebarPath is the location of the file in which the changes to the
+	// favourites and the collapsed sections are saved, as set by
+	// LoadSidebar
+	SidebarPath string `json:"-"`
+
+	// highlights are the compiled Highlights
+	highlights []*regexp.Regexp
+}
+
+// Workspace is the definition of a slack workspace, every workspace has its
+// own tokens and connection, and its own sidebar and notification levels.
+// When a workspace doesn't set them the ones of the Config are used.
+type Workspace struct {
+	Name        string            `json:"name"`
+	SlackToken  string            `json:"slack_token"`
+	AppToken    string            `json:"app_token"`
+	APIURL      string            `json:"api_url"`
+	EventSource string            `json:"event_source"`
+	Sidebar     *Sidebar          `json:"sidebar,omitempty"`
+	Levels      map[string]string `json:"notifications,omitempty"`
 }
 
 type keyMapping map[string]string
@@ -62,6 +130,72 @@ func NewConfig(filepath string) (*Config, error) {
 		return &cfg, fmt.Errorf("unsupported setting for notify: %s", cfg.Notify)
 	}
 
+	switch cfg.Notifier {
+	case NotifierDesktop, NotifierBell, NotifierOSC9, NotifierOSC777:
+		break
+	case NotifierCommand:
+		if cfg.NotifyCommand == "" {
+			return &cfg, errors.New("please specify the 'notify_command' to use the command notifier")
+		}
+	default:
+		return &cfg, fmt.Errorf("unsupported setting for notifier: %s", cfg.Notifier)
+	}
+
+	for event := range cfg.Hooks {
+		switch event {
+		case HookMessageReceived, HookMention, HookMessageSent, HookChannelChanged, HookMessageFilter:
+			break
+		default:
+			return &cfg, fmt.Errorf("unsupported event for hooks: %s", event)
+		}
+	}
+
+	if err := cfg.compileHighlights(); err != nil {
+		return &cfg, err
+	}
+
+	// Every workspace starts out with its own copy of the sidebar, so that
+	// the favourites of one workspace don't end up in the others
+	for i := range cfg.Workspaces {
+		if cfg.Workspaces[i].Sidebar == nil {
+			sidebar := cfg.Sidebar.Copy()
+			cfg.Workspaces[i].Sidebar = &sidebar
+		}
+		if cfg.Workspaces[i].Sidebar.Sort == "" {
+			cfg.Workspaces[i].Sidebar.Sort = cfg.Sidebar.Sort
+		}
+		if cfg.Workspaces[i].Levels == nil {
+			cfg.Workspaces[i].Levels = cfg.Levels
+		}
+	}
+
+	for _, workspace := range cfg.GetWorkspaces() {
+		workspace.Sidebar.keepConfig()
+
+		switch workspace.EventSource {
+		case EventSourceRTM, EventSourceSocketMode, "":
+			break
+		default:
+			return &cfg, fmt.Errorf("unsupported setting for event_source: %s", workspace.EventSource)
+		}
+
+		switch workspace.Sidebar.Sort {
+		case SortName, SortRecent:
+			break
+		default:
+			return &cfg, fmt.Errorf("unsupported setting for sort: %s", workspace.Sidebar.Sort)
+		}
+
+		for channel, level := range workspace.Levels {
+			switch level {
+			case LevelAll, LevelMentions, LevelNothing:
+				break
+			default:
+				return &cfg, fmt.Errorf("unsupported notification level for %s: %s", channel, level)
+			}
+		}
+	}
+
 	termui.ColorMap = map[string]termui.Attribute{
 		"fg":        termui.StringToAttribute(cfg.Theme.View.Fg),
 		"bg":        termui.StringToAttribute(cfg.Theme.View.Bg),
@@ -74,6 +208,37 @@ func NewConfig(filepath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// GetLevel returns the notification level of the channel, by its identifier
+// or its name, that is set for the workspace, it is empty when it isn't set
+func (w Workspace) GetLevel(id string, name string) string {
+	for channel, level := range w.Levels {
+		if MatchChannel([]string{channel}, id, name) == 0 {
+			return level
+		}
+	}
+	return ""
+}
+
+// GetWorkspaces returns the workspaces of the config, when none are set
+// the tokens, the sidebar and the notification levels of the config itself
+// make up the only workspace
+func (c *Config) GetWorkspaces() []Workspace {
+	if len(c.Workspaces) > 0 {
+		return c.Workspaces
+	}
+
+	return []Workspace{
+		{
+			SlackToken:  c.SlackToken,
+			AppToken:    c.AppToken,
+			APIURL:      c.APIURL,
+			EventSource: c.EventSource,
+			Sidebar:     &c.Sidebar,
+			Levels:      c.Levels,
+		},
+	}
+}
+
 func CreateConfigFile(filepath string) (*os.File, error) {
 	filepath = fmt.Sprintf("%s/slack-term/%s", xdg.ConfigHome(), "config")
 
@@ -101,7 +266,14 @@ func getDefaultConfig() Config {
 		MainWidth:    11,
 		ThreadsWidth: 1,
 		Notify:       "",
+		Notifier:     NotifierDesktop,
+		Bell:         true,
 		Emoji:        false,
+		Unreads:      false,
+		Cache:        true,
+		Sidebar: Sidebar{
+			Sort: SortName,
+		},
 		KeyMap: map[string]keyMapping{
 			"command": {
 				"i":          "mode-insert",
@@ -118,15 +290,35 @@ func getDefaultConfig() Config {
 				"<next>":     "chat-down",
 				"C-f":        "chat-down",
 				"C-d":        "chat-down",
+				"<up>":       "chat-select-up",
+				"<down>":     "chat-select-down",
+				"<escape>":   "chat-select-clear",
+				"<enter>":    "search-open",
 				"n":          "channel-search-next",
 				"N":          "channel-search-prev",
 				"'":          "channel-jump",
+				"b":          "channel-browse",
+				"C":          "channel-create",
+				"L":          "channel-leave",
+				"A":          "channel-archive",
+				"f":          "channel-favourite",
+				"z":          "section-toggle",
 				"q":          "quit",
+				"e":          "chat-edit",
+				"d":          "chat-delete",
+				"r":          "chat-react",
+				"o":          "thread-open",
+				"x":          "thread-close",
+				"w":          "workspace-next",
+				"W":          "workspace-prev",
 				"<f1>":       "help",
 			},
 			"insert": {
 				"<left>":      "cursor-left",
 				"<right>":     "cursor-right",
+				"<up>":        "chat-edit-prev",
+				"<down>":      "chat-edit-next",
+				"C-b":         "thread-broadcast",
 				"<enter>":     "send",
 				"<escape>":    "mode-command",
 				"<backspace>": "backspace",
@@ -134,11 +326,17 @@ func getDefaultConfig() Config {
 				"<delete>":    "delete",
 				"<space>":     "space",
 			},
+			"confirm": {
+				"y":        "confirm-yes",
+				"n":        "confirm-no",
+				"<escape>": "confirm-no",
+			},
 			"search": {
 				"<left>":      "cursor-left",
 				"<right>":     "cursor-right",
 				"<escape>":    "clear-input",
-				"<enter>":     "clear-input",
+				"<enter>":     "search-submit",
+				"<tab>":       "search-target",
 				"<backspace>": "backspace",
 				"C-8":         "backspace",
 				"<delete>":    "delete",
@@ -165,6 +363,7 @@ func getDefaultConfig() Config {
 				Thread:     "fg-bold",
 				Name:       "",
 				Text:       "",
+				Highlight:  "fg-yellow,fg-bold",
 			},
 		},
 	}
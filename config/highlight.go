@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// wordChar matches a character that is part of a word
+var wordChar = regexp.MustCompile(`\w`)
+
+// compileHighlights will compile the highlight rules, a rule is either a
+// regular expression between slashes, or a word that is matched as a whole
+// word regardless of its case
+func (c *Config) compileHighlights() error {
+	c.highlights = make([]*regexp.Regexp, 0)
+
+	for _, rule := range c.Highlights {
+		if rule == "" {
+			continue
+		}
+
+		var expr string
+		if len(rule) > 2 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+			expr = rule[1 : len(rule)-1]
+		} else {
+			expr = `(?i)` + regexp.QuoteMeta(rule)
+
+			// Words that start or end with a symbol, like @oncall,
+			// are bounded by the absence of a word boundary
+			if wordChar.MatchString(rule[:1]) {
+				expr = `\b` + expr
+			} else {
+				expr = `\B` + expr
+			}
+			if wordChar.MatchString(rule[len(rule)-1:]) {
+				expr = expr + `\b`
+			} else {
+				expr = expr + `\B`
+			}
+		}
+
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid highlight %s: %v", rule, err)
+		}
+		c.highlights = append(c.highlights, r)
+	}
+
+	return nil
+}
+
+// GetHighlights returns the compiled highlight rules
+func (c *Config) GetHighlights() []*regexp.Regexp {
+	return c.highlights
+}
+
+// IsHighlight returns whether the text matches one of the highlight rules
+func (c *Config) IsHighlight(text string) bool {
+	for _, r := range c.highlights {
+		if r.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
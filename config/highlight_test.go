@@ -0,0 +1,45 @@
+package config
+
+import "testing"
+
+func TestIsHighlight(t *testing.T) {
+	tests := []struct {
+		rule string
+		text string
+		want bool
+	}{
+		{"deploy", "Deploy is done", true},
+		{"deploy", "the DEPLOY failed", true},
+		{"deploy", "redeploy is done", false},
+		{"deploy", "deployment is done", false},
+		{"@oncall", "ping @oncall please", true},
+		{"@oncall", "ping @ONCALL", true},
+		{"@oncall", "mail x@oncall", false},
+		{"c++", "who knows c++?", true},
+		{"c++", "who knows abc++", false},
+		{"a.b", "axb", false},
+		{"/deploy(ed|ing)/", "redeployed", true},
+		{"/deploy(ed|ing)/", "Deployed", false},
+		{"/(?i)^urgent/", "URGENT: fix", true},
+		{"/(?i)^urgent/", "not urgent", false},
+		{"/", "a / b", true},
+	}
+
+	for _, test := range tests {
+		c := &Config{Highlights: []string{test.rule}}
+		if err := c.compileHighlights(); err != nil {
+			t.Fatalf("%s: %v", test.rule, err)
+		}
+
+		if got := c.IsHighlight(test.text); got != test.want {
+			t.Errorf("%s in %q: expected %v, got %v", test.rule, test.text, test.want, got)
+		}
+	}
+}
+
+func TestCompileHighlightsInvalid(t *testing.T) {
+	c := &Config{Highlights: []string{"deploy", "/deploy(/"}}
+	if err := c.compileHighlights(); err == nil {
+		t.Error("the invalid regular expression was accepted")
+	}
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	SortName   = "name"
+	SortRecent = "recent"
+)
+
+// Sidebar is the definition of how the channels are shown in the Channels
+// pane. The channels are referred to by their name, or by their identifier.
+type Sidebar struct {
+	// Sections are shown in order, below the Favourites, every section has
+	// the channels in the order they're listed. The channels that aren't
+	// in a section are shown in the Channels and Direct messages sections.
+	Sections []Section `json:"sections,omitempty"`
+
+	// Favourites are shown in the Favourites section at the top
+	Favourites []string `json:"favourites,omitempty"`
+
+	// Collapsed are the names of the sections that are collapsed, they
+	// only show the selected channel and the unread channels
+	Collapsed []string `json:"collapsed,omitempty"`
+
+	// Muted are the channels that are muted, they're hidden when HideMuted
+	// is set
+	Muted     []string `json:"muted,omitempty"`
+	HideMuted bool     `json:"hide_muted,omitempty"`
+
+	// Sort is the order of the channels in every section, by their name
+	// or with the most recent activity first
+	Sort string `json:"sort,omitempty"`
+
+	// configFavourites and configCollapsed are the Favourites and the
+	// Collapsed of the config file, the changes to them are saved apart
+	configFavourites []string
+	configCollapsed  []string
+}
+
+// Section is a section of the Channels pane with the channels in it
+type Section struct {
+	Name     string   `json:"name"`
+	Channels []string `json:"channels"`
+}
+
+// HasSections returns whether the channels are divided into sections, which
+// is the case when there are favourites or sections
+func (s *Sidebar) HasSections() bool {
+	return len(s.Sections) > 0 || len(s.Favourites) > 0
+}
+
+// IsCollapsed returns whether the section with the name is collapsed
+func (s *Sidebar) IsCollapsed(name string) bool {
+	return indexOf(s.Collapsed, name) >= 0
+}
+
+// ToggleCollapsed will collapse the section with the name, or expand it
+// when it is collapsed
+func (s *Sidebar) ToggleCollapsed(name string) {
+	s.Collapsed = toggle(s.Collapsed, name)
+}
+
+// IsFavourite returns whether the channel is one of the favourites
+func (s *Sidebar) IsFavourite(id string, name string) bool {
+	return MatchChannel(s.Favourites, id, name) >= 0
+}
+
+// ToggleFavourite will add the channel to the favourites, or remove it when
+// it is one of them
+func (s *Sidebar) ToggleFavourite(id string, name string) {
+	if i := MatchChannel(s.Favourites, id, name); i >= 0 {
+		s.Favourites = append(s.Favourites[:i], s.Favourites[i+1:]...)
+	} else {
+		s.Favourites = append(s.Favourites, name)
+	}
+}
+
+// Copy returns a copy of the sidebar, which can be changed without changing
+// the sidebar it was copied from
+func (s Sidebar) Copy() Sidebar {
+	s.Sections = append([]Section(nil), s.Sections...)
+	s.Favourites = append([]string(nil), s.Favourites...)
+	s.Collapsed = append([]string(nil), s.Collapsed...)
+	s.Muted = append([]string(nil), s.Muted...)
+	s.configFavourites = append([]string(nil), s.configFavourites...)
+	s.configCollapsed = append([]string(nil), s.configCollapsed...)
+
+	return s
+}
+
+// IsMuted returns whether the channel is muted
+func (s *Sidebar) IsMuted(id string, name string) bool {
+	return MatchChannel(s.Muted, id, name) >= 0
+}
+
+// MatchChannel returns the index of the channel in the list of channels,
+// by its identifier or its name, and -1 when it isn't in it. A leading #
+// or @ of the names in the list is ignored.
+func MatchChannel(channels []string, id string, name string) int {
+	for i, channel := range channels {
+		channel = strings.TrimLeft(channel, "#@")
+		if channel == id || channel == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// sidebarState is the part of the sidebar of a workspace that is changed
+// from within slack-term, it contains the favourites and the collapsed
+// sections that were added to the ones of the config file, and the ones
+// that were removed from them
+type sidebarState struct {
+	Favourites   []string `json:"favourites,omitempty"`
+	Unfavourites []string `json:"unfavourites,omitempty"`
+	Collapsed    []string `json:"collapsed,omitempty"`
+	Expanded     []string `json:"expanded,omitempty"`
+}
+
+// sidebarKey returns the key of the workspace at the index in the sidebar
+// state, which is its name, or its position when it doesn't have one
+func sidebarKey(index int, workspace Workspace) string {
+	if workspace.Name != "" {
+		return workspace.Name
+	}
+	return strconv.Itoa(index)
+}
+
+// keepConfig will remember the favourites and the collapsed sections of
+// the config file, the changes to them are saved
+func (s *Sidebar) keepConfig() {
+	s.configFavourites = append([]string(nil), s.Favourites...)
+	s.configCollapsed = append([]string(nil), s.Collapsed...)
+}
+
+// state returns the changes to the favourites and the collapsed sections
+// of the config file
+func (s *Sidebar) state() sidebarState {
+	return sidebarState{
+		Favourites:   difference(s.Favourites, s.configFavourites),
+		Unfavourites: difference(s.configFavourites, s.Favourites),
+		Collapsed:    difference(s.Collapsed, s.configCollapsed),
+		Expanded:     difference(s.configCollapsed, s.Collapsed),
+	}
+}
+
+// applyState will apply the changes of the state to the favourites and the
+// collapsed sections of the config file
+func (s *Sidebar) applyState(state sidebarState) {
+	s.Favourites = append(
+		difference(s.configFavourites, state.Unfavourites),
+		difference(state.Favourites, s.configFavourites)...,
+	)
+	s.Collapsed = append(
+		difference(s.configCollapsed, state.Expanded),
+		difference(state.Collapsed, s.configCollapsed)...,
+	)
+}
+
+// LoadSidebar will set the SidebarPath, and apply the changes to the
+// favourites and the collapsed sections that were saved in it to the ones
+// of the config file. Without a SidebarPath the changes aren't saved.
+func (c *Config) LoadSidebar(path string) error {
+	c.SidebarPath = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	state := make(map[string]sidebarState)
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	for i, workspace := range c.GetWorkspaces() {
+		if saved, ok := state[sidebarKey(i, workspace)]; ok {
+			workspace.Sidebar.applyState(saved)
+		}
+	}
+
+	return nil
+}
+
+// SaveSidebar will store the changes to the favourites and the collapsed
+// sections of the workspaces in the file at the SidebarPath, the config
+// file isn't changed
+func (c *Config) SaveSidebar() error {
+	if c.SidebarPath == "" {
+		return nil
+	}
+
+	state := make(map[string]sidebarState)
+	for i, workspace := range c.GetWorkspaces() {
+		state[sidebarKey(i, workspace)] = workspace.Sidebar.state()
+	}
+
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(fp.Dir(c.SidebarPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.SidebarPath, append(data, '\n'), 0600)
+}
+
+// indexOf returns the index of the value in the values, and -1 when it
+// isn't in them
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// difference returns the values that aren't in the others, in the order of
+// the values
+func difference(values []string, others []string) []string {
+	result := make([]string, 0)
+	for _, v := range values {
+		if indexOf(others, v) < 0 {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// toggle will add the value to the values, or remove it when it is in them
+func toggle(values []string, value string) []string {
+	if i := indexOf(values, value); i >= 0 {
+		return append(values[:i], values[i+1:]...)
+	}
+	return append(values, value)
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestConfig returns the config of the json, which is written to a
+// temporary file
+func newTestConfig(t *testing.T, dir string, data string) *Config {
+	t.Helper()
+
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestSaveSidebar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slack-term-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := `{
+		"slack_token": "xoxp-test",
+		"sidebar": {
+			"favourites": ["#general", "#random"],
+			"sections": [{"name": "Team", "channels": ["#team"]}],
+			"collapsed": ["Team"]
+		}
+	}`
+	statePath := filepath.Join(dir, "state", "sidebar")
+
+	// Without a path nothing is saved
+	cfg := newTestConfig(t, dir, data)
+	cfg.Sidebar.ToggleFavourite("C1", "dev")
+	if err := cfg.SaveSidebar(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Fatal("the sidebar was saved without a path")
+	}
+
+	cfg = newTestConfig(t, dir, data)
+	if err := cfg.LoadSidebar(statePath); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sidebar.ToggleFavourite("C2", "random")
+	cfg.Sidebar.ToggleFavourite("C3", "dev")
+	cfg.Sidebar.ToggleCollapsed("Team")
+	if err := cfg.SaveSidebar(); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved, _ := ioutil.ReadFile(filepath.Join(dir, "config")); string(saved) != data {
+		t.Errorf("the config file was changed:\n%s", saved)
+	}
+
+	// The changes are applied to the config file, a favourite that was
+	// added to it since is kept
+	cfg = newTestConfig(t, dir, `{
+		"slack_token": "xoxp-test",
+		"sidebar": {
+			"favourites": ["#general", "#random", "#alerts"],
+			"sections": [{"name": "Team", "channels": ["#team"]}],
+			"collapsed": ["Team"]
+		}
+	}`)
+	if err := cfg.LoadSidebar(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"#general", "#alerts", "dev"}; !reflect.DeepEqual(cfg.Sidebar.Favourites, want) {
+		t.Errorf("expected the favourites %v, got %v", want, cfg.Sidebar.Favourites)
+	}
+	if len(cfg.Sidebar.Collapsed) != 0 {
+		t.Errorf("expected no collapsed sections, got %v", cfg.Sidebar.Collapsed)
+	}
+}
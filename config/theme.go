@@ -20,6 +20,7 @@ type Message struct {
 	Name       string `json:"name"`
 	Thread     string `json:"thread"`
 	Text       string `json:"text"`
+	Highlight  string `json:"highlight"`
 	TimeFormat string `json:"time_format"`
 }
 
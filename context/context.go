@@ -1,16 +1,16 @@
 package context
 
 import (
-	"errors"
+	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 
-	"github.com/0xAX/notificator"
 	"github.com/erroneousboat/termui"
 	termbox "github.com/nsf/termbox-go"
 
 	"github.com/erroneousboat/slack-term/config"
+	"github.com/erroneousboat/slack-term/notify"
 	"github.com/erroneousboat/slack-term/service"
 	"github.com/erroneousboat/slack-term/views"
 )
@@ -19,11 +19,18 @@ const (
 	CommandMode = "command"
 	InsertMode  = "insert"
 	SearchMode  = "search"
+	ConfirmMode = "confirm"
 
 	ChatFocus = iota
 	ThreadFocus
 )
 
+const (
+	SearchChannels = "channels"
+	SearchMessages = "messages"
+	SearchUsers    = "users"
+)
+
 type AppContext struct {
 	Version    string
 	Usage      string
@@ -35,12 +42,57 @@ type AppContext struct {
 	Debug      bool
 	Mode       string
 	Focus      int
-	Notify     *notificator.Notificator
+	Notifier   notify.Notifier
+
+	// EditMessage is the ID of the message that is being edited, when
+	// set sending the input will update this message
+	EditMessage string
+
+	// Broadcast will make the reply that is sent in a thread also show up
+	// in the channel
+	Broadcast bool
+
+	// Confirm is the action that will be executed when the prompt of the
+	// confirm mode is answered with yes
+	Confirm func(*AppContext)
+
+	// SearchTarget is what is searched in the search mode, the channels,
+	// the messages or the users
+	SearchTarget string
+
+	// SearchResults are the messages or users that were found, by the ID
+	// of the message in the Chat pane, as long as they're shown
+	SearchResults map[string]service.SearchResult
+
+	// Workspaces are the slack workspaces the user is signed in to, the
+	// Service and View are the ones of the selected workspace
+	Workspaces        []*Workspace
+	SelectedWorkspace int
+}
+
+// Workspace is a slack workspace with its own connection, and its own
+// Channels, Chat and Threads components. The Input, Mode and Debug
+// components are shared between the workspaces.
+type Workspace struct {
+	Name    string
+	Service *service.SlackService
+	View    *views.View
+
+	// Focus is the focus of the View when the workspace was left
+	Focus int
+
+	// Connection is the state of the connection with the workspace
+	Connection string
+
+	// Unread is set when a message was received while the workspace
+	// wasn't selected
+	Unread bool
 }
 
 // CreateAppContext creates an application context which can be passed
-// and referenced througout the application
-func CreateAppContext(flgConfig string, flgToken string, flgDebug bool, version string, usage string) (*AppContext, error) {
+// and referenced througout the application, the changes to the sidebar
+// are saved in the sidebarFile
+func CreateAppContext(flgConfig string, flgToken string, flgBackend string, flgDebug bool, version string, usage string, sidebarFile string) (*AppContext, error) {
 	if flgDebug {
 		go func() {
 			http.ListenAndServe(":6060", nil)
@@ -56,6 +108,10 @@ func CreateAppContext(flgConfig string, flgToken string, flgDebug bool, version
 		return nil, err
 	}
 
+	if err := config.LoadSidebar(sidebarFile); err != nil {
+		return nil, fmt.Errorf("couldn't load the saved sidebar: (%v)", err)
+	}
+
 	// When slack token isn't set in the config file, we'll check
 	// the command-line flag or the environment variable
 	if config.SlackToken == "" {
@@ -66,80 +122,76 @@ func CreateAppContext(flgConfig string, flgToken string, flgDebug bool, version
 		}
 	}
 
-	// Create desktop notifier
-	var notify *notificator.Notificator
+	// The app token, used for socket mode, can be set with an environment
+	// variable as well
+	if config.AppToken == "" {
+		config.AppToken = os.Getenv("SLACK_APP_TOKEN")
+	}
+
+	// Create the notifier, that delivers the notifications
+	var notifier notify.Notifier
 	if config.Notify != "" {
-		notify = notificator.New(notificator.Options{AppName: "slack-term"})
-		if notify == nil {
-			return nil, errors.New(
-				"desktop notifications are not supported for your OS",
-			)
+		notifier, err = notify.New(config)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Create Service
-	svc, err := service.NewSlackService(config)
-	if err != nil {
-		return nil, err
-	}
+	// Create a service and a view for every workspace
+	var workspaces []*Workspace
+	for i, wsConfig := range config.GetWorkspaces() {
 
-	// Create the main view
-	view, err := views.CreateView(config, svc)
-	if err != nil {
-		return nil, err
-	}
+		// Create the backend, by default this is the slack api
+		backend, err := service.NewBackend(flgBackend, wsConfig)
+		if err != nil {
+			return nil, err
+		}
 
-	columns := []*termui.Row{
-		termui.NewCol(config.SidebarWidth, 0, view.Channels),
+		// Create Service
+		svc, err := service.NewSlackService(config, wsConfig, backend)
+		if err != nil {
+			if wsConfig.Name != "" {
+				return nil, fmt.Errorf("%s: %s", wsConfig.Name, err.Error())
+			}
+			return nil, err
+		}
+
+		// Create the view of the workspace
+		view, err := views.CreateView(config, svc)
+		if err != nil {
+			return nil, err
+		}
+
+		// The Input, Mode and Debug components are shared
+		if i > 0 {
+			view.Input = workspaces[0].View.Input
+			view.Mode = workspaces[0].View.Mode
+			view.Debug = workspaces[0].View.Debug
+		}
+
+		name := wsConfig.Name
+		if name == "" {
+			name = svc.CurrentTeam
+		}
+
+		workspaces = append(workspaces, &Workspace{
+			Name:    name,
+			Service: svc,
+			View:    view,
+			Focus:   ChatFocus,
+		})
 	}
 
+	svc := workspaces[0].Service
+	view := workspaces[0].View
+
 	threads := false
 	if len(view.Threads.ChannelItems) > 0 {
 		threads = true
 	}
 
 	// Setup the interface
-	if threads && flgDebug {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(config.MainWidth-config.ThreadsWidth-3, 0, view.Chat),
-				termui.NewCol(config.ThreadsWidth, 0, view.Threads),
-				termui.NewCol(3, 0, view.Debug),
-			}...,
-		)
-	} else if threads {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(config.MainWidth-config.ThreadsWidth, 0, view.Chat),
-				termui.NewCol(config.ThreadsWidth, 0, view.Threads),
-			}...,
-		)
-	} else if flgDebug {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(config.MainWidth-5, 0, view.Chat),
-				termui.NewCol(config.MainWidth-6, 0, view.Debug),
-			}...,
-		)
-	} else {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(config.MainWidth, 0, view.Chat),
-			}...,
-		)
-	}
-
-	termui.Body.AddRows(
-		termui.NewRow(columns...),
-		termui.NewRow(
-			termui.NewCol(config.SidebarWidth, 0, view.Mode),
-			termui.NewCol(config.MainWidth, 0, view.Input),
-		),
-	)
+	view.Layout(termui.Body, threads, flgDebug)
 
 	termui.Body.Align()
 	termui.Render(termui.Body)
@@ -155,6 +207,14 @@ func CreateAppContext(flgConfig string, flgToken string, flgDebug bool, version
 		Debug:      flgDebug,
 		Mode:       CommandMode,
 		Focus:      ChatFocus,
-		Notify:     notify,
+		Notifier:   notifier,
+
+		SearchTarget: SearchChannels,
+		Workspaces:   workspaces,
 	}, nil
 }
+
+// GetWorkspace returns the selected workspace
+func (ctx *AppContext) GetWorkspace() *Workspace {
+	return ctx.Workspaces[ctx.SelectedWorkspace]
+}
@@ -6,7 +6,7 @@ require (
 	github.com/0xAX/notificator v0.0.0-20171022182052-88d57ee9043b
 	github.com/OpenPeeDeeP/xdg v0.2.0
 	github.com/erroneousboat/termui v0.0.0-20170923115141-80f245cdfa04
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/lithammer/fuzzysearch v1.1.0
 	github.com/maruel/panicparse v1.1.1 // indirect
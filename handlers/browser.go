@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/service"
+)
+
+// actionBrowseChannels will show the public channels of the workspace in
+// the Chat pane, with their amount of members and their topic. The selected
+// channel can be joined, left or archived.
+func actionBrowseChannels(ctx *context.AppContext) {
+	channels, err := ctx.Service.GetPublicChannels()
+	if err != nil {
+		actionError(ctx, fmt.Errorf("couldn't load the channels: %s", err.Error()))
+		return
+	}
+
+	current := ctx.View.Channels.GetSelectedChannel().ID
+
+	var selected string
+	results := make([]service.SearchResult, 0, len(channels))
+	msgs := make([]components.Message, 0, len(channels))
+	for _, channel := range channels {
+		result := createBrowserResult(channel)
+		results = append(results, result)
+		msgs = append(msgs, result.Message)
+
+		if selected == "" || channel.ID == current {
+			selected = result.Message.ID
+		}
+	}
+
+	actionSetSearchResults(ctx, getBrowserLabel(len(results)), results, msgs, selected)
+}
+
+// createBrowserResult will create how a channel is shown in the channel
+// browser, the ID sorts the channels by their name
+func createBrowserResult(channel service.PublicChannel) service.SearchResult {
+	content := fmt.Sprintf("%s %s", components.IconChannel, channel.Name)
+	if channel.IsMember {
+		content += " (joined)"
+	}
+
+	if channel.Members == 1 {
+		content += "  1 member"
+	} else {
+		content += fmt.Sprintf("  %d members", channel.Members)
+	}
+
+	if channel.Topic != "" {
+		content += "  " + channel.Topic
+	}
+
+	return service.SearchResult{
+		ChannelID: channel.ID,
+		Channel:   &channel,
+		Message: components.Message{
+			ID:         channel.Name + channel.ID,
+			Content:    content,
+			Selectable: true,
+		},
+	}
+}
+
+// getBrowserLabel returns the label of the Chat pane when it shows the
+// channel browser
+func getBrowserLabel(count int) string {
+	return fmt.Sprintf("Channels (%d)", count)
+}
+
+// actionJoinChannel will show the channel, when the user isn't a member of
+// it yet it is joined first and added to the channels pane
+func actionJoinChannel(ctx *context.AppContext, channelID string) {
+	index := ctx.View.Channels.FindChannel(channelID)
+	if index < 0 {
+		item, err := ctx.Service.JoinChannel(channelID)
+		if err != nil {
+			actionError(ctx, fmt.Errorf("couldn't join the channel: %s", err.Error()))
+			return
+		}
+
+		index = ctx.View.Channels.AddChannel(item)
+	}
+
+	ctx.View.Channels.GotoPosition(index)
+	actionChangeChannel(ctx)
+}
+
+// actionCreateChannel will switch to insert mode with the create command in
+// the input, the name of the channel can then be typed and sent
+func actionCreateChannel(ctx *context.AppContext) {
+	actionInsertMode(ctx)
+
+	ctx.View.Input.SetText("/create #")
+	render(ctx.View.Input)
+}
+
+// actionSendCreateChannel will create the channel with slack, add it to the
+// channels pane and show it
+func actionSendCreateChannel(ctx *context.AppContext, name string) {
+	item, err := ctx.Service.CreateChannel(name)
+	if err != nil {
+		actionError(ctx, fmt.Errorf("couldn't create the channel: %s", err.Error()))
+		return
+	}
+
+	index := ctx.View.Channels.AddChannel(item)
+	ctx.View.Channels.GotoPosition(index)
+	actionChangeChannel(ctx)
+}
+
+// actionLeaveChannel will ask for confirmation to leave the channel that is
+// selected in the channel browser, or else the current channel
+func actionLeaveChannel(ctx *context.AppContext) {
+	channelID, name, err := getManagedChannel(ctx)
+	if err != nil {
+		actionError(ctx, err)
+		return
+	}
+
+	actionConfirm(ctx, fmt.Sprintf("LEAVE #%s? y/n", name), func(ctx *context.AppContext) {
+		if err := ctx.Service.LeaveChannel(channelID); err != nil {
+			actionError(ctx, fmt.Errorf("couldn't leave the channel: %s", err.Error()))
+			return
+		}
+
+		actionUpdateBrowser(ctx, channelID, false)
+		actionRemoveChannel(ctx, channelID)
+	})
+}
+
+// actionArchiveChannel will ask for confirmation to archive the channel
+// that is selected in the channel browser, or else the current channel
+func actionArchiveChannel(ctx *context.AppContext) {
+	channelID, name, err := getManagedChannel(ctx)
+	if err != nil {
+		actionError(ctx, err)
+		return
+	}
+
+	actionConfirm(ctx, fmt.Sprintf("ARCHIVE #%s? y/n", name), func(ctx *context.AppContext) {
+		if err := ctx.Service.ArchiveChannel(channelID); err != nil {
+			actionError(ctx, fmt.Errorf("couldn't archive the channel: %s", err.Error()))
+			return
+		}
+
+		actionUpdateBrowser(ctx, channelID, true)
+		actionRemoveChannel(ctx, channelID)
+	})
+}
+
+// getManagedChannel returns the channel that is selected in the channel
+// browser, when it is shown, or else the current channel. Direct messages
+// can't be left or archived.
+func getManagedChannel(ctx *context.AppContext) (string, string, error) {
+	if msg, ok := ctx.View.Chat.GetSelectedMessage(); ok {
+		if result, ok := ctx.SearchResults[msg.ID]; ok && result.Channel != nil {
+			return result.Channel.ID, result.Channel.Name, nil
+		}
+	}
+
+	channel := ctx.View.Channels.GetSelectedChannel()
+	if channel.Type != components.ChannelTypeChannel && channel.Type != components.ChannelTypeGroup {
+		return "", "", errors.New("only channels can be left or archived")
+	}
+
+	return channel.ID, channel.Name, nil
+}
+
+// actionRemoveChannel will remove a channel that was left or archived from
+// the channels pane, when it was shown the next channel is shown instead
+func actionRemoveChannel(ctx *context.AppContext, channelID string) {
+	wasSelected := ctx.View.Channels.GetSelectedChannel().ID == channelID
+
+	ctx.View.Channels.RemoveChannel(channelID)
+	actionSetUnreads(ctx)
+
+	if wasSelected && ctx.SearchResults == nil {
+		actionChangeChannel(ctx)
+	} else {
+		render(ctx.View.Channels)
+	}
+}
+
+// actionUpdateBrowser will show that the user left the channel in the
+// channel browser, when it is shown. A channel that was archived is removed
+// from it.
+func actionUpdateBrowser(ctx *context.AppContext, channelID string, archived bool) {
+	for id, result := range ctx.SearchResults {
+		if result.Channel == nil || result.Channel.ID != channelID {
+			continue
+		}
+
+		selected := ctx.View.Chat.SelectedMessage
+
+		ctx.View.Chat.DeleteMessage(id)
+		delete(ctx.SearchResults, id)
+
+		if !archived {
+			channel := *result.Channel
+			if channel.IsMember {
+				channel.IsMember = false
+				channel.Members--
+			}
+
+			result = createBrowserResult(channel)
+			ctx.View.Chat.AddMessage(result.Message)
+			ctx.SearchResults[result.Message.ID] = result
+			ctx.View.Chat.SetSelectedMessage(selected)
+		}
+
+		ctx.View.Chat.SetBorderLabel(getBrowserLabel(len(ctx.SearchResults)))
+		render(ctx.View.Chat)
+		return
+	}
+}
+
+// parseCreateCommand returns the name of the channel when the message is a
+// create command, e.g.:
+//
+//	/create #project-x
+func parseCreateCommand(message string) (string, bool) {
+	r := regexp.MustCompile(`^/create\s+#?(\S+)\s*$`)
+
+	rs := r.FindStringSubmatch(message)
+	if len(rs) < 2 {
+		return "", false
+	}
+
+	return strings.ToLower(rs[1]), true
+}
@@ -2,14 +2,14 @@ package handlers
 
 import (
 	"fmt"
-	"log"
+	"html"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/0xAX/notificator"
 	"github.com/erroneousboat/termui"
 	termbox "github.com/nsf/termbox-go"
 	"github.com/slack-go/slack"
@@ -17,11 +17,36 @@ import (
 	"github.com/erroneousboat/slack-term/components"
 	"github.com/erroneousboat/slack-term/config"
 	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/hooks"
+	"github.com/erroneousboat/slack-term/notify"
+	"github.com/erroneousboat/slack-term/service"
 	"github.com/erroneousboat/slack-term/views"
 )
 
 var scrollTimer *time.Timer
-var notifyTimer *time.Timer
+
+var (
+	// channelDone is closed when another channel is shown, it stops the
+	// loading of replies and the retries of requests for the channel
+	channelDone  chan struct{}
+	channelMutex sync.Mutex
+
+	// olderDone is the channelDone of the channel of which the older
+	// messages are being loaded, they're loaded one page at a time
+	olderDone <-chan struct{}
+)
+
+// notifications combines the notifications of a channel before they're
+// delivered by the notifier of the context
+var notifications *notify.Coalescer
+
+// metricsInterval is the interval in which the metrics of the requests to
+// slack are shown in the Debug component
+const metricsInterval = 30 * time.Second
+
+// notifyDelay is the time in which the notifications of a channel are
+// combined
+const notifyDelay = 2 * time.Second
 
 // actionMap binds specific action names to the function counterparts,
 // these action names can then be used to bind them to specific keys
@@ -44,11 +69,35 @@ var actionMap = map[string]func(*context.AppContext){
 	"channel-bottom":      actionMoveCursorBottomChannels,
 	"channel-search-next": actionSearchNextChannels,
 	"channel-search-prev": actionSearchPrevChannels,
+	"search-target":       actionToggleSearchTarget,
+	"search-submit":       actionSubmitSearch,
+	"search-open":         actionOpenSearchResult,
 	"channel-jump":        actionJumpChannels,
+	"channel-browse":      actionBrowseChannels,
+	"channel-create":      actionCreateChannel,
+	"channel-leave":       actionLeaveChannel,
+	"channel-archive":     actionArchiveChannel,
+	"channel-favourite":   actionToggleFavourite,
+	"section-toggle":      actionToggleSection,
 	"thread-up":           actionMoveCursorUpThreads,
 	"thread-down":         actionMoveCursorDownThreads,
 	"chat-up":             actionScrollUpChat,
 	"chat-down":           actionScrollDownChat,
+	"chat-select-up":      actionSelectUpChat,
+	"chat-select-down":    actionSelectDownChat,
+	"chat-select-clear":   actionSelectClearChat,
+	"chat-edit":           actionEditMessage,
+	"chat-edit-prev":      actionEditPrevMessage,
+	"chat-edit-next":      actionEditNextMessage,
+	"chat-delete":         actionDeleteMessage,
+	"chat-react":          actionReact,
+	"thread-open":         actionOpenSelectedThread,
+	"thread-close":        actionCloseThread,
+	"thread-broadcast":    actionToggleBroadcast,
+	"workspace-next":      actionNextWorkspace,
+	"workspace-prev":      actionPrevWorkspace,
+	"confirm-yes":         actionConfirmYes,
+	"confirm-no":          actionConfirmNo,
 	"help":                actionHelp,
 }
 
@@ -61,8 +110,56 @@ func Initialize(ctx *context.AppContext) {
 	// RTM incoming events
 	messageHandler(ctx)
 
-	// User presence
-	go actionSetPresenceAll(ctx)
+	// Show the workspaces when there are more than one
+	actionSetWorkspaceLabel(ctx)
+
+	// Notifications of a channel that arrive in short succession are
+	// delivered as one
+	if ctx.Notifier != nil {
+		notifications = notify.NewCoalescer(ctx.Notifier, notifyDelay, func(err error) {
+			actionError(ctx, fmt.Errorf("couldn't send the notification: %s", err.Error()))
+		})
+	}
+
+	// Commands that are run on the events, and on the outgoing messages
+	setEventHooks(hooks.New(ctx.Config.Hooks, func(err error) {
+		actionError(ctx, err)
+	}))
+
+	for _, ws := range ctx.Workspaces {
+		go func(ws *context.Workspace) {
+			// Muted channels and the notification levels of the
+			// channels
+			actionLoadNotificationPrefs(ctx, ws)
+
+			// User groups, their mentions are mentions of the user
+			// when they're a member
+			actionLoadUserGroups(ctx, ws)
+
+			// The channels that were shown from the cache are
+			// reconciled first, they're replaced
+			if ws.Service.Cache != nil {
+				actionReloadChannels(ctx, ws)
+			}
+
+			// User presence
+			go actionSetPresenceAll(ctx, ws)
+
+			// Unread messages of the channels in the Unreads section,
+			// and the latest activity to sort the channels by
+			if ctx.Config.Unreads || ws.Service.Workspace.Sidebar.Sort == config.SortRecent {
+				go actionLoadUnreads(ctx, ws)
+			}
+		}(ws)
+	}
+
+	// Replies of the threads in the first channel
+	actionLoadReplies(ctx)
+
+	// Metrics of the requests to slack
+	if ctx.Debug {
+		go actionSchedulerMetrics(ctx)
+	}
 }
 
 // eventHandler will handle events created by the user
@@ -116,72 +213,148 @@ func handleMoreTermboxEvents(ctx *context.AppContext, ev termbox.Event) bool {
 
 // messageHandler will handle events created by the service
 func messageHandler(ctx *context.AppContext) {
-	go func() {
-		for {
-			select {
-			case rtmEvent := <-ctx.Service.RTM.IncomingEvents:
-				switch ev := rtmEvent.Data.(type) {
-				case *slack.MessageEvent:
-
-					// Construct message
-					msg, err := ctx.Service.CreateMessageFromMessageEvent(ev, ev.Channel)
-					if err != nil {
-						continue
-					}
+	for _, ws := range ctx.Workspaces {
+		go workspaceMessageHandler(ctx, ws)
+	}
+}
 
-					// Add message to the selected channel
-					if ev.Channel == ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID {
-
-						// Get the thread timestamp of the event, we need to
-						// check the previous message as well, because edited
-						// message don't have the thread timestamp
-						var threadTimestamp string
-						if ev.ThreadTimestamp != "" {
-							threadTimestamp = ev.ThreadTimestamp
-						} else if ev.PreviousMessage != nil && ev.PreviousMessage.ThreadTimestamp != "" {
-							threadTimestamp = ev.PreviousMessage.ThreadTimestamp
-						} else {
-							threadTimestamp = ""
-						}
+// workspaceMessageHandler will handle the events of a workspace, the events
+// of a workspace that isn't selected are only used to keep track of what is
+// unread
+func workspaceMessageHandler(ctx *context.AppContext, ws *context.Workspace) {
+	for {
+		select {
+		case rtmEvent := <-ws.Service.IncomingEvents:
+			if ws != ctx.GetWorkspace() {
+				actionBackgroundEvent(ctx, ws, rtmEvent)
+				continue
+			}
 
-						// When timestamp isn't set this is a thread reply,
-						// handle as such
-						if threadTimestamp != "" {
-							ctx.View.Chat.AddReply(threadTimestamp, msg)
-						} else if threadTimestamp == "" && ctx.Focus == context.ChatFocus {
-							ctx.View.Chat.AddMessage(msg)
-						}
+			switch ev := rtmEvent.Data.(type) {
+			case *slack.MessageEvent:
 
-						// we (mis)use actionChangeChannel, to rerender, the
-						// view when a new thread has been started
-						if ctx.View.Chat.IsNewThread(threadTimestamp) {
-							actionChangeChannel(ctx)
-						} else {
-							termui.Render(ctx.View.Chat)
-						}
+				// Remove deleted messages, this includes replies
+				if ev.SubType == "message_deleted" {
+					actionRemoveMessage(ctx, ws, ev.Channel, ev.DeletedTimestamp)
+					continue
+				}
+
+				// Construct message
+				msg, err := ws.Service.CreateMessageFromMessageEvent(ev, ev.Channel)
+				if err != nil {
+					continue
+				}
 
-						// TODO: set Chat.Offset to 0, to automatically scroll
-						// down?
+				// The activity of the channel, edits don't count
+				if ev.SubType != "message_changed" {
+					ws.View.Channels.SetLatest(ev.Channel, time.Now())
+					renderWorkspace(ctx, ws, ws.View.Channels)
+				}
+
+				// Add message to the selected channel, unless search
+				// results are shown instead. The workspace could've been
+				// switched in the meantime.
+				if ev.Channel == ws.View.Channels.ChannelItems[ws.View.Channels.SelectedChannel].ID &&
+					ws == ctx.GetWorkspace() && ctx.SearchResults == nil {
+
+					// Get the thread timestamp of the event, we need to
+					// check the previous message as well, because edited
+					// message don't have the thread timestamp
+					var threadTimestamp string
+					if ev.ThreadTimestamp != "" {
+						threadTimestamp = ev.ThreadTimestamp
+					} else if ev.PreviousMessage != nil && ev.PreviousMessage.ThreadTimestamp != "" {
+						threadTimestamp = ev.PreviousMessage.ThreadTimestamp
+					} else {
+						threadTimestamp = ""
 					}
 
-					// Set new message indicator for channel, I'm leaving
-					// this here because I also want to be notified when
-					// I'm currently in a channel but not in the terminal
-					// window (tmux). But only create a notification when
-					// it comes from someone else but the current user.
-					if ev.User != ctx.Service.CurrentUserID {
-						actionNewMessage(ctx, ev)
+					// When timestamp isn't set this is a thread reply,
+					// handle as such. When a thread is opened only its
+					// replies are added.
+					if threadTimestamp == msg.ID {
+						ws.View.Chat.AddMessage(msg)
+					} else if threadTimestamp != "" && ctx.Focus == context.ThreadFocus {
+						if threadTimestamp == ws.View.Chat.Thread {
+							ws.View.Chat.AddReply(threadTimestamp, msg)
+						}
+					} else if threadTimestamp != "" {
+						ws.View.Chat.AddReply(threadTimestamp, msg)
+					} else if threadTimestamp == "" && ctx.Focus == context.ChatFocus {
+						ws.View.Chat.AddMessage(msg)
 					}
-				case *slack.PresenceChangeEvent:
-					actionSetPresence(ctx, ev.User, ev.Presence)
-				case *slack.RTMError:
-					ctx.View.Debug.Println(
-						ev.Error(),
+
+					// we (mis)use actionChangeChannel, to rerender, the
+					// view when a new thread has been started
+					if ws.View.Chat.IsNewThread(threadTimestamp) && ws == ctx.GetWorkspace() {
+						actionChangeChannel(ctx)
+					} else {
+						renderWorkspace(ctx, ws, ws.View.Chat)
+					}
+
+					// TODO: set Chat.Offset to 0, to automatically scroll
+					// down?
+				}
+
+				// Set new message indicator for channel, I'm leaving
+				// this here because I also want to be notified when
+				// I'm currently in a channel but not in the terminal
+				// window (tmux). But only create a notification when
+				// it comes from someone else but the current user.
+				// Edited messages carry the user in the SubMessage.
+				user := ev.User
+				if ev.SubMessage != nil {
+					user = ev.SubMessage.User
+				}
+
+				if user != ws.Service.CurrentUserID {
+					actionNewMessage(ctx, ws, ev)
+				}
+			case *slack.ReactionAddedEvent:
+				if ev.Item.Channel == ws.View.Channels.ChannelItems[ws.View.Channels.SelectedChannel].ID {
+					ws.View.Chat.AddReaction(
+						ev.Item.Timestamp,
+						ws.Service.CreateReaction(ev.Reaction),
+						ev.User,
 					)
+					renderWorkspace(ctx, ws, ws.View.Chat)
 				}
+			case *slack.ReactionRemovedEvent:
+				if ev.Item.Channel == ws.View.Channels.ChannelItems[ws.View.Channels.SelectedChannel].ID {
+					ws.View.Chat.RemoveReaction(
+						ev.Item.Timestamp,
+						ev.Reaction,
+						ev.User,
+					)
+					renderWorkspace(ctx, ws, ws.View.Chat)
+				}
+			case *slack.PresenceChangeEvent:
+				actionSetPresence(ctx, ws, ev.User, ev.Presence)
+			case *slack.ConnectingEvent:
+				actionSetConnection(ctx, ws, components.ConnectionConnecting)
+			case *slack.ConnectedEvent:
+				actionSetConnection(ctx, ws, components.ConnectionConnected)
+
+				// We've reconnected, catch up on what we missed. The
+				// other workspaces catch up once they're selected.
+				if ev.ConnectionCount > 1 && ws == ctx.GetWorkspace() {
+					go actionBackfill(ctx, ws)
+				}
+			case *slack.DisconnectedEvent:
+				if !ev.Intentional {
+					actionSetConnection(ctx, ws, components.ConnectionOffline)
+				}
+			case *slack.ConnectionErrorEvent:
+				actionSetConnection(ctx, ws, components.ConnectionOffline)
+				actionError(ctx, fmt.Errorf(
+					"couldn't connect, retrying in %s: %s",
+					ev.Backoff.Round(time.Second), ev.Error(),
+				))
+			case *slack.RTMError:
+				actionError(ctx, ev)
 			}
 		}
-	}()
+	}
 }
 
 func actionKeyEvent(ctx *context.AppContext, ev termbox.Event) {
@@ -193,6 +366,13 @@ func actionKeyEvent(ctx *context.AppContext, ev termbox.Event) {
 	// the associated function with this key and execute
 	// it.
 	actionStr, ok := ctx.Config.KeyMap[ctx.Mode][keyStr]
+
+	// The input is disabled while the message that was sent is filtered,
+	// only the insert mode can be left
+	if ctx.Mode == context.InsertMode && isFiltering() && actionStr != "mode-command" {
+		return
+	}
+
 	if ok {
 		action, ok := actionMap[actionStr]
 		if ok {
@@ -210,82 +390,39 @@ func actionKeyEvent(ctx *context.AppContext, ev termbox.Event) {
 func actionResizeEvent(ctx *context.AppContext, ev termbox.Event) {
 	// When terminal window is too small termui will panic, here
 	// we won't resize when the terminal window is too small.
-	if termui.TermWidth() < 25 || termui.TermHeight() < 5 {
+	width, height := termSize()
+	if width < 25 || height < 5 {
 		return
 	}
 
-	termui.Body.Width = termui.TermWidth()
+	termui.Body.Width = width
 
 	// Vertical resize components
-	ctx.View.Channels.List.Height = termui.TermHeight() - ctx.View.Input.Par.Height
-	ctx.View.Chat.List.Height = termui.TermHeight() - ctx.View.Input.Par.Height
-	ctx.View.Debug.List.Height = termui.TermHeight() - ctx.View.Input.Par.Height
+	ctx.View.Resize(height)
 
 	termui.Body.Align()
-	termui.Render(termui.Body)
+	render(termui.Body)
 }
 
 func actionRedrawGrid(ctx *context.AppContext, threads bool, debug bool) {
-	termui.Clear()
+	width, _ := termSize()
+
+	clearTerm()
 	termui.Body = termui.NewGrid()
 	termui.Body.X = 0
 	termui.Body.Y = 0
 	termui.Body.BgColor = termui.ThemeAttr("bg")
-	termui.Body.Width = termui.TermWidth()
-
-	columns := []*termui.Row{
-		termui.NewCol(ctx.Config.SidebarWidth, 0, ctx.View.Channels),
-	}
-
-	if threads && debug {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(ctx.Config.MainWidth-ctx.Config.ThreadsWidth-3, 0, ctx.View.Chat),
-				termui.NewCol(ctx.Config.ThreadsWidth, 0, ctx.View.Threads),
-				termui.NewCol(3, 0, ctx.View.Debug),
-			}...,
-		)
-	} else if threads {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(ctx.Config.MainWidth-ctx.Config.ThreadsWidth, 0, ctx.View.Chat),
-				termui.NewCol(ctx.Config.ThreadsWidth, 0, ctx.View.Threads),
-			}...,
-		)
-	} else if debug {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(ctx.Config.MainWidth-5, 0, ctx.View.Chat),
-				termui.NewCol(ctx.Config.MainWidth-6, 0, ctx.View.Debug),
-			}...,
-		)
-	} else {
-		columns = append(
-			columns,
-			[]*termui.Row{
-				termui.NewCol(ctx.Config.MainWidth, 0, ctx.View.Chat),
-			}...,
-		)
-	}
+	termui.Body.Width = width
 
-	termui.Body.AddRows(
-		termui.NewRow(columns...),
-		termui.NewRow(
-			termui.NewCol(ctx.Config.SidebarWidth, 0, ctx.View.Mode),
-			termui.NewCol(ctx.Config.MainWidth, 0, ctx.View.Input),
-		),
-	)
+	ctx.View.Layout(termui.Body, threads, debug)
 
 	termui.Body.Align()
-	termui.Render(termui.Body)
+	render(termui.Body)
 }
 
 func actionInput(view *views.View, key rune) {
 	view.Input.Insert(key)
-	termui.Render(view.Input)
+	render(view.Input)
 }
 
 func actionClearInput(ctx *context.AppContext) {
@@ -303,22 +440,22 @@ func actionSpace(ctx *context.AppContext) {
 
 func actionBackSpace(ctx *context.AppContext) {
 	ctx.View.Input.Backspace()
-	termui.Render(ctx.View.Input)
+	render(ctx.View.Input)
 }
 
 func actionDelete(ctx *context.AppContext) {
 	ctx.View.Input.Delete()
-	termui.Render(ctx.View.Input)
+	render(ctx.View.Input)
 }
 
 func actionMoveCursorRight(ctx *context.AppContext) {
 	ctx.View.Input.MoveCursorRight()
-	termui.Render(ctx.View.Input)
+	render(ctx.View.Input)
 }
 
 func actionMoveCursorLeft(ctx *context.AppContext) {
 	ctx.View.Input.MoveCursorLeft()
-	termui.Render(ctx.View.Input)
+	render(ctx.View.Input)
 }
 
 func actionSend(ctx *context.AppContext) {
@@ -328,7 +465,34 @@ func actionSend(ctx *context.AppContext) {
 		// quick succession of actionSend
 		message := ctx.View.Input.GetText()
 		ctx.View.Input.Clear()
-		termui.Render(ctx.View.Input)
+		render(ctx.View.Input)
+
+		// Update the message when we're editing one
+		if ctx.EditMessage != "" {
+			err := ctx.Service.UpdateMessage(
+				ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
+				ctx.EditMessage,
+				message,
+			)
+			if err != nil {
+				actionError(ctx, err)
+			}
+
+			actionCancelEdit(ctx)
+			return
+		}
+
+		// Toggle a reaction on the selected message
+		if name, ok := parseReactCommand(message); ok {
+			actionToggleReaction(ctx, name)
+			return
+		}
+
+		// Create a channel
+		if name, ok := parseCreateCommand(message); ok {
+			actionSendCreateChannel(ctx, name)
+			return
+		}
 
 		// Send slash command
 		isCmd, err := ctx.Service.SendCommand(
@@ -336,37 +500,27 @@ func actionSend(ctx *context.AppContext) {
 			message,
 		)
 		if err != nil {
-			ctx.View.Debug.Println(
-				err.Error(),
-			)
+			actionError(ctx, err)
 		}
 
 		// Send message
 		if !isCmd {
-			if ctx.Focus == context.ChatFocus {
-				err := ctx.Service.SendMessage(
-					ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
-					message,
-				)
-				if err != nil {
-					ctx.View.Debug.Println(
-						err.Error(),
-					)
-				}
+			channel := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel]
 
+			thread := ""
+			broadcast := false
+			if ctx.Focus == context.ThreadFocus {
+				thread = ctx.View.Chat.Thread
+				broadcast = ctx.Broadcast
+				actionSetBroadcast(ctx, false)
 			}
 
-			if ctx.Focus == context.ThreadFocus {
-				err := ctx.Service.SendReply(
-					ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
-					ctx.View.Threads.ChannelItems[ctx.View.Threads.SelectedChannel].ID,
-					message,
-				)
-				if err != nil {
-					ctx.View.Debug.Println(
-						err.Error(),
-					)
-				}
+			// The filter hooks replace the message, they run in the
+			// background
+			if getEventHooks().Has(config.HookMessageFilter) {
+				actionFilterMessage(ctx, ctx.Service, channel, thread, broadcast, message)
+			} else {
+				sendMessage(ctx, ctx.Service, channel, thread, broadcast, message)
 			}
 		}
 
@@ -375,9 +529,28 @@ func actionSend(ctx *context.AppContext) {
 		if channelItem.Notification {
 			ctx.Service.MarkAsRead(channelItem)
 			ctx.View.Channels.MarkAsRead(ctx.View.Channels.SelectedChannel)
+			actionSetUnreads(ctx)
 		}
-		termui.Render(ctx.View.Channels)
+		render(ctx.View.Channels)
+	}
+}
+
+// sendMessage will send the message to the channel, or as a reply to the
+// thread when it is set
+func sendMessage(ctx *context.AppContext, svc *service.SlackService, channel components.ChannelItem, thread string, broadcast bool, message string) {
+	var err error
+	if thread == "" {
+		err = svc.SendMessage(channel.ID, message)
+	} else {
+		err = svc.SendReply(channel.ID, thread, message, broadcast)
+	}
+
+	if err != nil {
+		actionError(ctx, err)
+		return
 	}
+
+	actionSentHooks(ctx, channel, message, thread)
 }
 
 // actionSearch will search through the channels based on the users
@@ -386,6 +559,11 @@ func actionSend(ctx *context.AppContext) {
 func actionSearch(ctx *context.AppContext, key rune) {
 	actionInput(ctx.View, key)
 
+	// The messages and users are searched when the input is submitted
+	if ctx.SearchTarget != context.SearchChannels {
+		return
+	}
+
 	go func() {
 		if scrollTimer != nil {
 			scrollTimer.Stop()
@@ -413,32 +591,59 @@ func actionQuit(ctx *context.AppContext) {
 func actionInsertMode(ctx *context.AppContext) {
 	ctx.Mode = context.InsertMode
 	ctx.View.Mode.SetInsertMode()
+	render(ctx.View.Mode)
 }
 
 func actionCommandMode(ctx *context.AppContext) {
+	actionCancelEdit(ctx)
+
 	ctx.Mode = context.CommandMode
 	ctx.View.Mode.SetCommandMode()
+	render(ctx.View.Mode)
 }
 
 func actionSearchMode(ctx *context.AppContext) {
 	ctx.Mode = context.SearchMode
-	ctx.View.Mode.SetSearchMode()
+	switch ctx.SearchTarget {
+	case context.SearchMessages:
+		ctx.View.Mode.SetSearchMessagesMode()
+	case context.SearchUsers:
+		ctx.View.Mode.SetSearchUsersMode()
+	default:
+		ctx.View.Mode.SetSearchMode()
+	}
+	render(ctx.View.Mode)
 }
 
+// actionGetMessages will replace the messages in the Chat pane with the
+// latest messages of the selected channel, and load the replies of their
+// threads
 func actionGetMessages(ctx *context.AppContext) {
-	msgs, _, err := ctx.Service.GetMessages(
-		ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
-		ctx.View.Chat.GetMaxItems(),
-	)
-	if err != nil {
-		termbox.Close()
-		log.Println(err)
-		os.Exit(0)
-	}
-
-	ctx.View.Chat.SetMessages(msgs)
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+
+	var msgs []components.Message
+	actionRetry(
+		ctx,
+		"couldn't load the messages",
+		func() error {
+			var err error
+			msgs, _, err = ctx.Service.GetMessages(
+				channelID,
+				ctx.View.Chat.GetMaxItems(),
+			)
+			return err
+		},
+		func() {
+			// The channel could've been changed while retrying
+			if channelID != ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID {
+				return
+			}
 
-	termui.Render(ctx.View.Chat)
+			ctx.View.Chat.SetMessages(msgs)
+			actionLoadReplies(ctx)
+			render(ctx.View.Chat)
+		},
+	)
 }
 
 // actionMoveCursorUpChannels will execute the actionChangeChannel
@@ -451,7 +656,7 @@ func actionMoveCursorUpChannels(ctx *context.AppContext) {
 		}
 
 		ctx.View.Channels.MoveCursorUp()
-		termui.Render(ctx.View.Channels)
+		render(ctx.View.Channels)
 
 		scrollTimer = time.NewTimer(time.Second / 4)
 		<-scrollTimer.C
@@ -471,7 +676,7 @@ func actionMoveCursorDownChannels(ctx *context.AppContext) {
 		}
 
 		ctx.View.Channels.MoveCursorDown()
-		termui.Render(ctx.View.Channels)
+		render(ctx.View.Channels)
 
 		scrollTimer = time.NewTimer(time.Second / 4)
 		<-scrollTimer.C
@@ -502,27 +707,88 @@ func actionSearchPrevChannels(ctx *context.AppContext) {
 }
 
 func actionJumpChannels(ctx *context.AppContext) {
+	if ctx.Config.Unreads {
+		actionJumpUnread(ctx)
+		return
+	}
+
 	ctx.View.Channels.Jump()
 	actionChangeChannel(ctx)
 }
 
 func actionChangeChannel(ctx *context.AppContext) {
+	changeChannel(ctx, nil)
+}
+
+// changeChannel will show the selected channel, loaded is executed once
+// its messages have been loaded from slack, when it's set
+func changeChannel(ctx *context.AppContext, loaded func()) {
+	actionChannelHooks(ctx)
+
+	// The replies and retries of the previous channel won't be shown
+	// anymore
+	stopChannel()
+
+	// Stop editing, the message won't be in the Chat pane anymore
+	actionCancelEdit(ctx)
+	actionSetBroadcast(ctx, false)
+
+	// Closing an opened thread changes the layout
+	wasThread := ctx.View.Chat.Thread != ""
+
 	// Clear messages from Chat pane
 	ctx.View.Chat.ClearMessages()
+	ctx.SearchResults = nil
+
+	// Set focus, necessary to know when replying to thread or chat
+	ctx.Focus = context.ChatFocus
 
 	// Get messages of the SelectedChannel, and get the count of messages
-	// that fit into the Chat component
-	msgs, threads, err := ctx.Service.GetMessages(
-		ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
+	// that fit into the Chat component. Until slack has responded, or a
+	// retry has succeeded, the cached or the empty channel is shown. The
+	// messages of slack replace them, and then the replies of their
+	// threads are loaded.
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+
+	msgs, threads, _ := ctx.Service.GetCachedMessages(
+		channelID,
 		ctx.View.Chat.GetMaxItems(),
 	)
-	if err != nil {
-		termbox.Close()
-		log.Println(err)
-		os.Exit(0)
-	}
+	actionShowChannel(ctx, msgs, threads, wasThread)
+
+	actionRetry(
+		ctx,
+		"couldn't load the messages",
+		func() error {
+			var err error
+			msgs, threads, err = ctx.Service.GetMessages(
+				channelID,
+				ctx.View.Chat.GetMaxItems(),
+			)
+			return err
+		},
+		func() {
+			// The channel could've been changed, or a thread opened,
+			// while retrying
+			if channelID != ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID ||
+				ctx.View.Chat.Thread != "" {
+				return
+			}
+
+			actionShowChannel(ctx, msgs, threads, wasThread)
+			actionLoadReplies(ctx)
 
-	// Set messages for the channel
+			if loaded != nil {
+				loaded()
+			}
+		},
+	)
+}
+
+// actionShowChannel will show the messages of the selected channel in the
+// Chat pane, they replace the ones that are shown, and its threads in the
+// Threads pane
+func actionShowChannel(ctx *context.AppContext, msgs []components.Message, threads []components.ChannelItem, wasThread bool) {
 	ctx.View.Chat.SetMessages(msgs)
 
 	// Set the threads identifiers in the threads pane
@@ -552,6 +818,7 @@ func actionChangeChannel(ctx *context.AppContext) {
 	if channelItem.Notification {
 		ctx.Service.MarkAsRead(channelItem)
 		ctx.View.Channels.MarkAsRead(ctx.View.Channels.SelectedChannel)
+		actionSetUnreads(ctx)
 	}
 
 	// Redraw grid, necessary when threads and/or debug is set. We will redraw
@@ -560,59 +827,380 @@ func actionChangeChannel(ctx *context.AppContext) {
 	// Threads.
 	if haveThreads {
 		actionRedrawGrid(ctx, haveThreads, ctx.Debug)
-	} else if !haveThreads && len(ctx.View.Threads.ChannelItems) > 0 {
+	} else if !haveThreads && (len(ctx.View.Threads.ChannelItems) > 0 || wasThread) {
 		ctx.View.Threads.SetChannels([]components.ChannelItem{})
 		actionRedrawGrid(ctx, haveThreads, ctx.Debug)
 	} else {
-		termui.Render(ctx.View.Threads)
-		termui.Render(ctx.View.Channels)
-		termui.Render(ctx.View.Chat)
+		render(ctx.View.Threads)
+		render(ctx.View.Channels)
+		render(ctx.View.Chat)
+	}
+}
+
+func actionChangeThread(ctx *context.AppContext) {
+	// The first channel in the Thread list is current Channel, otherwise
+	// open the thread
+	if ctx.View.Threads.SelectedChannel == 0 {
+		actionCloseThread(ctx)
+	} else {
+		actionOpenThread(
+			ctx,
+			ctx.View.Threads.ChannelItems[ctx.View.Threads.SelectedChannel].ID,
+		)
+	}
+}
+
+// actionOpenThread will show the thread in the Chat pane, only the first
+// page of replies is loaded. The next pages are loaded when scrolling down.
+func actionOpenThread(ctx *context.AppContext, threadID string) {
+	openThread(ctx, threadID, nil)
+}
+
+// openThread will show the thread in the Chat pane, loaded is executed
+// once its first page of replies has been loaded, when it's set
+func openThread(ctx *context.AppContext, threadID string, loaded func()) {
+	// Stop editing, the message won't be in the Chat pane anymore
+	actionCancelEdit(ctx)
+
+	channel := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel]
+
+	var parent components.Message
+	var replies []components.Message
+	var cursor string
+	actionRetry(
+		ctx,
+		"couldn't load the thread",
+		func() error {
+			var err error
+			parent, replies, cursor, err = ctx.Service.GetThread(
+				channel.ID,
+				threadID,
+				"",
+				ctx.View.Chat.GetMaxItems(),
+			)
+			return err
+		},
+		func() {
+			// The channel or thread could've been changed while retrying
+			if channel.ID != ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID {
+				return
+			}
+
+			if ctx.View.Chat.Thread != "" && ctx.View.Chat.Thread != threadID {
+				return
+			}
+
+			ctx.View.Chat.OpenThread(parent, replies, cursor)
+			ctx.View.Chat.SetBorderLabel(
+				fmt.Sprintf("Thread %s- %s", parent.Thread, channel.GetChannelName()),
+			)
+
+			// Set focus, necessary to know when replying to thread or chat
+			ctx.Focus = context.ThreadFocus
+
+			actionRedrawGrid(ctx, len(ctx.View.Threads.ChannelItems) > 0, ctx.Debug)
+
+			if loaded != nil {
+				loaded()
+			}
+		},
+	)
+}
+
+// actionCloseThread will return to the channel from the thread, the
+// channel is shown at the position it was left
+func actionCloseThread(ctx *context.AppContext) {
+	if ctx.View.Chat.Thread == "" {
+		return
+	}
+
+	actionCancelEdit(ctx)
+	actionSetBroadcast(ctx, false)
+
+	ctx.View.Chat.CloseThread()
+
+	// Add the messages that were sent while the thread was opened
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+
+	var msgs []components.Message
+	actionRetry(
+		ctx,
+		"couldn't load the messages",
+		func() error {
+			var err error
+			msgs, _, err = ctx.Service.GetMessages(
+				channelID,
+				ctx.View.Chat.GetMaxItems(),
+			)
+			return err
+		},
+		func() {
+			// A thread could've been opened while retrying
+			if ctx.View.Chat.Thread != "" {
+				return
+			}
+
+			for _, msg := range msgs {
+				ctx.View.Chat.AddMessage(msg)
+			}
+			actionLoadReplies(ctx)
+
+			render(ctx.View.Chat)
+		},
+	)
+
+	if len(ctx.View.Threads.ChannelItems) > 0 {
+		ctx.View.Threads.MoveCursorTop()
 	}
 
-	// Set focus, necessary to know when replying to thread or chat
 	ctx.Focus = context.ChatFocus
+
+	actionRedrawGrid(ctx, len(ctx.View.Threads.ChannelItems) > 0, ctx.Debug)
 }
 
-func actionChangeThread(ctx *context.AppContext) {
-	// Clear messages from Chat pane
-	ctx.View.Chat.ClearMessages()
+// actionLoadReplies will fetch the replies of the threads in the Chat pane
+// that aren't loaded yet. This is done in the background, the replies are
+// added to the Chat pane as they arrive.
+func actionLoadReplies(ctx *context.AppContext) {
+	if ctx.View.Chat.Thread != "" {
+		return
+	}
 
-	// The first channel in the Thread list is current Channel. Set context
-	// Focus and messages accordingly.
-	var err error
-	msgs := []components.Message{}
-	if ctx.View.Threads.SelectedChannel == 0 {
-		ctx.Focus = context.ChatFocus
+	var threadIDs []string
+	for _, msg := range ctx.View.Chat.Messages {
+		if msg.ReplyCount > 0 && !msg.HasReplies() {
+			threadIDs = append(threadIDs, msg.ID)
+		}
+	}
 
-		msgs, _, err = ctx.Service.GetMessages(
-			ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
-			ctx.View.Chat.GetMaxItems(),
-		)
-		if err != nil {
-			termbox.Close()
-			log.Println(err)
-			os.Exit(0)
+	if len(threadIDs) == 0 {
+		return
+	}
+
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+
+	done := doneChannel()
+
+	go func() {
+		for replies := range ctx.Service.GetReplies(channelID, threadIDs, done) {
+			// Another channel is shown, the replies are dropped
+			select {
+			case <-done:
+				continue
+			default:
+			}
+
+			if replies.Err != nil {
+				actionError(ctx, fmt.Errorf(
+					"couldn't load replies of thread %s: %s",
+					replies.ThreadID, replies.Err.Error(),
+				))
+				continue
+			}
+
+			// The channel could've been changed, or a thread opened,
+			// before the replies arrived
+			if replies.ChannelID != ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID ||
+				ctx.View.Chat.Thread != "" {
+				continue
+			}
+
+			if _, ok := ctx.View.Chat.Messages[replies.ThreadID]; !ok {
+				continue
+			}
+
+			for _, reply := range replies.Messages {
+				ctx.View.Chat.AddReply(replies.ThreadID, reply)
+			}
+
+			render(ctx.View.Chat)
 		}
-	} else {
-		ctx.Focus = context.ThreadFocus
+	}()
+}
 
-		msgs, err = ctx.Service.GetMessageByID(
-			ctx.View.Threads.ChannelItems[ctx.View.Threads.SelectedChannel].ID,
-			ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
+// doneChannel returns the channel that is closed when another channel is
+// shown
+func doneChannel() <-chan struct{} {
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+
+	if channelDone == nil {
+		channelDone = make(chan struct{})
+	}
+
+	return channelDone
+}
+
+// stopChannel will stop the loading of replies and the retries of requests
+// that were started for the channel that is shown, the results that are
+// still in flight are dropped
+func stopChannel() {
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+
+	if channelDone != nil {
+		close(channelDone)
+		channelDone = nil
+	}
+}
+
+// startOlder returns whether the older messages of the channel that is shown
+// can be loaded, which isn't the case while they're already being loaded.
+// The release function allows them to be loaded again.
+func startOlder() (func(), bool) {
+	done := doneChannel()
+
+	channelMutex.Lock()
+	defer channelMutex.Unlock()
+
+	if olderDone == done {
+		return nil, false
+	}
+	olderDone = done
+
+	release := func() {
+		channelMutex.Lock()
+		defer channelMutex.Unlock()
+
+		if olderDone == done {
+			olderDone = nil
+		}
+	}
+
+	return release, true
+}
+
+// actionSetConnection will set the state of the connection with the
+// workspace, it is shown when the workspace is the selected one
+func actionSetConnection(ctx *context.AppContext, ws *context.Workspace, state string) {
+	ws.Connection = state
+
+	if ws == ctx.GetWorkspace() {
+		ws.View.Mode.SetConnection(state)
+		render(ws.View.Mode)
+	}
+}
+
+// actionBackfill will fetch what was missed in the workspace while we were
+// disconnected: the messages of the channel that is shown, and which
+// channels have become unread
+func actionBackfill(ctx *context.AppContext, ws *context.Workspace) {
+	channelID := ws.View.Channels.GetSelectedChannel().ID
+
+	// When a thread is open the messages of the channel are fetched when
+	// it is closed
+	if oldest := ws.View.Chat.GetNewestMessageID(); oldest != "" && ws.View.Chat.Thread == "" {
+		msgs, threads, hasMore, err := ws.Service.GetNewerMessages(
+			channelID, oldest, ws.View.Chat.GetMaxItems(),
 		)
 		if err != nil {
-			termbox.Close()
-			log.Println(err)
-			os.Exit(0)
+			// Checking the channels would most likely fail the same way
+			actionError(ctx, fmt.Errorf("couldn't load the missed messages: %s", err.Error()))
+			return
+		}
+
+		// The messages are dropped when the user moved on to another
+		// workspace, channel or a thread
+		if ws == ctx.GetWorkspace() &&
+			channelID == ctx.View.Channels.GetSelectedChannel().ID &&
+			ctx.View.Chat.Thread == "" {
+
+			if hasMore {
+				// We've missed more than fits, start over with the
+				// latest messages
+				actionGetMessages(ctx)
+			} else {
+				for _, msg := range msgs {
+					ctx.View.Chat.AddMessage(msg)
+				}
+				actionLoadReplies(ctx)
+				render(ctx.View.Chat)
+			}
+
+			// Newer threads are placed before the ones that are already
+			// in the threads pane
+			if len(threads) > 0 {
+				if len(ctx.View.Threads.ChannelItems) > 0 {
+					items := append([]components.ChannelItem{ctx.View.Threads.ChannelItems[0]}, threads...)
+					ctx.View.Threads.SetChannels(
+						append(items, ctx.View.Threads.ChannelItems[1:]...),
+					)
+					render(ctx.View.Threads)
+				} else {
+					ctx.View.Threads.SetChannels(
+						append(
+							[]components.ChannelItem{ctx.View.Channels.GetSelectedChannel()},
+							threads...,
+						),
+					)
+					ctx.View.Threads.MoveCursorTop()
+					actionRedrawGrid(ctx, true, ctx.Debug)
+				}
+			}
 		}
 	}
 
-	// Set messages for the channel
-	ctx.View.Chat.SetMessages(msgs)
+	// Mark the other channels that have become unread
+	actionLoadUnreads(ctx, ws)
+}
+
+// actionOpenSelectedThread will open the thread of the selected message,
+// when the message isn't part of a thread it will open a new one
+func actionOpenSelectedThread(ctx *context.AppContext) {
+	msg, ok := ctx.View.Chat.GetSelectedMessage()
+	if !ok {
+		return
+	}
+
+	threadID := msg.ThreadID
+	if threadID == "" {
+		threadID = msg.ID
+	}
+
+	actionOpenThread(ctx, threadID)
+}
+
+// actionGetReplies will fetch the next page of replies of the thread that
+// is opened, when there is one
+func actionGetReplies(ctx *context.AppContext) {
+	if ctx.View.Chat.Thread == "" || ctx.View.Chat.ThreadCursor == "" {
+		return
+	}
+
+	_, replies, cursor, err := ctx.Service.GetThread(
+		ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID,
+		ctx.View.Chat.Thread,
+		ctx.View.Chat.ThreadCursor,
+		ctx.View.Chat.GetMaxItems(),
+	)
+	if err != nil {
+		actionError(ctx, err)
+		return
+	}
+
+	ctx.View.Chat.AddReplies(replies, cursor)
+}
+
+// actionToggleBroadcast will toggle whether a reply in a thread is also
+// sent to the channel
+func actionToggleBroadcast(ctx *context.AppContext) {
+	if ctx.Focus != context.ThreadFocus {
+		return
+	}
 
-	termui.Render(ctx.View.Channels)
-	termui.Render(ctx.View.Threads)
-	termui.Render(ctx.View.Chat)
+	actionSetBroadcast(ctx, !ctx.Broadcast)
+}
+
+func actionSetBroadcast(ctx *context.AppContext, broadcast bool) {
+	ctx.Broadcast = broadcast
+
+	if broadcast {
+		ctx.View.Input.SetBorderLabel("Also send to channel")
+	} else {
+		ctx.View.Input.SetBorderLabel("")
+	}
+
+	render(ctx.View.Input)
 }
 
 func actionMoveCursorUpThreads(ctx *context.AppContext) {
@@ -622,7 +1210,7 @@ func actionMoveCursorUpThreads(ctx *context.AppContext) {
 		}
 
 		ctx.View.Threads.MoveCursorUp()
-		termui.Render(ctx.View.Threads)
+		render(ctx.View.Threads)
 
 		scrollTimer = time.NewTimer(time.Second / 4)
 		<-scrollTimer.C
@@ -639,7 +1227,7 @@ func actionMoveCursorDownThreads(ctx *context.AppContext) {
 		}
 
 		ctx.View.Threads.MoveCursorDown()
-		termui.Render(ctx.View.Threads)
+		render(ctx.View.Threads)
 
 		scrollTimer = time.NewTimer(time.Second / 4)
 		<-scrollTimer.C
@@ -649,62 +1237,521 @@ func actionMoveCursorDownThreads(ctx *context.AppContext) {
 	}()
 }
 
-// actionNewMessage will set the new message indicator for a channel, and
-// if configured will also display a desktop notification
-func actionNewMessage(ctx *context.AppContext, ev *slack.MessageEvent) {
-	ctx.View.Channels.MarkAsUnread(ev.Channel)
-	termui.Render(ctx.View.Channels)
+// actionNewMessage will set the new message indicator for a channel of the
+// workspace, and the workspace itself when it isn't selected, and if
+// configured will also display a desktop notification and run the hooks of
+// the message
+func actionNewMessage(ctx *context.AppContext, ws *context.Workspace, ev *slack.MessageEvent) {
+	// The channel and the user are looked up within the context of the
+	// workspace
+	wsCtx := *ctx
+	wsCtx.Service = ws.Service
+	wsCtx.View = ws.View
+
+	if addUnread(&wsCtx, ev) {
+		if ws != ctx.GetWorkspace() {
+			ws.Unread = true
+			actionSetWorkspaceLabel(ctx)
+		}
+		actionSetUnreads(ctx)
+		renderWorkspace(ctx, ws, ws.View.Channels)
+	}
 
-	// Terminal bell
-	fmt.Print("\a")
+	actionNotify(&wsCtx, ev)
+	actionMessageHooks(&wsCtx, ev)
+}
 
-	// Desktop notification
-	if ctx.Config.Notify == config.NotifyMention {
-		if isMention(ctx, ev) {
-			createNotifyMessage(ctx, ev)
+// actionNotify will notify the user of the new message with the terminal
+// bell, when it is enabled, and with the notifier. Depending on the
+// notification level of the channel only mentions are notified, or nothing
+// at all. The channels that aren't in the channels pane aren't notified.
+func actionNotify(ctx *context.AppContext, ev *slack.MessageEvent) {
+	channel, ok := ctx.View.Channels.GetChannel(ev.Channel)
+	if !ok {
+		return
+	}
+	mention := isMention(ctx, ev)
+
+	switch getLevel(ctx.Service, channel) {
+	case config.LevelNothing:
+		return
+	case config.LevelMentions:
+		if !mention {
+			return
 		}
-	} else if ctx.Config.Notify == config.NotifyAll {
-		createNotifyMessage(ctx, ev)
 	}
+
+	// Terminal bell
+	if ctx.Config.Bell {
+		fmt.Print("\a")
+	}
+
+	// Notification
+	if notifications == nil || ctx.Config.Notify == config.NotifyMention && !mention {
+		return
+	}
+
+	go func() {
+		notification := createNotification(ctx, channel, ev, mention)
+		if err := notifications.Notify(notification); err != nil {
+			actionError(ctx, fmt.Errorf("couldn't send the notification: %s", err.Error()))
+		}
+	}()
 }
 
-func actionSetPresence(ctx *context.AppContext, channelID string, presence string) {
-	ctx.View.Channels.SetPresence(channelID, presence)
-	termui.Render(ctx.View.Channels)
+func actionSetPresence(ctx *context.AppContext, ws *context.Workspace, channelID string, presence string) {
+	ws.View.Channels.SetPresence(channelID, presence)
+	renderWorkspace(ctx, ws, ws.View.Channels)
 }
 
-// actionPresenceAll will set the presence of the user list. Because the
-// requests to the endpoint are rate limited we implement a timeout here.
-func actionSetPresenceAll(ctx *context.AppContext) {
-	for _, chn := range ctx.Service.Conversations {
+// actionPresenceAll will set the presence of the user list of the
+// workspace. The requests to the endpoint are rate limited, they are spread
+// out by the scheduler of the service.
+func actionSetPresenceAll(ctx *context.AppContext, ws *context.Workspace) {
+	for _, chn := range ws.Service.Conversations {
 		if chn.IsIM {
 
-			presence, err := ctx.Service.GetUserPresence(chn.User)
+			presence, err := ws.Service.GetUserPresence(chn.User)
 			if err != nil {
 				presence = "away"
 			}
-			ctx.View.Channels.SetPresence(chn.ID, presence)
+			ws.View.Channels.SetPresence(chn.ID, presence)
 
-			termui.Render(ctx.View.Channels)
-			time.Sleep(1200 * time.Millisecond)
+			if ws == ctx.GetWorkspace() {
+				render(ctx.View.Channels)
+			}
 		}
 	}
 }
 
+// actionReloadChannels will replace the channels of the workspace, which
+// were shown from the cache, with the ones of slack. What has changed since
+// they were shown, like the unread messages, is kept. The messages of the
+// channel that is shown are reloaded as well.
+func actionReloadChannels(ctx *context.AppContext, ws *context.Workspace) {
+	items, err := ws.Service.GetChannels()
+	if err != nil {
+		actionError(ctx, fmt.Errorf("couldn't load the channels: %s", err.Error()))
+		return
+	}
+
+	shown := make(map[string]components.ChannelItem)
+	for _, item := range ws.View.Channels.ChannelItems {
+		shown[item.ID] = item
+	}
+
+	for i, item := range items {
+		if old, ok := shown[item.ID]; ok {
+			items[i].Presence = old.Presence
+			items[i].Notification = old.Notification
+			items[i].UnreadCount = old.UnreadCount
+			items[i].MentionCount = old.MentionCount
+			if old.Latest.After(items[i].Latest) {
+				items[i].Latest = old.Latest
+			}
+		}
+	}
+
+	// The selected channel could've been left or archived, the first
+	// channel is selected instead
+	selected := ws.View.Channels.GetSelectedChannel().ID
+	ws.View.Channels.SetChannels(items)
+	if index := ws.View.Channels.FindChannel(selected); index >= 0 {
+		ws.View.Channels.GotoPosition(index)
+	} else {
+		ws.View.Channels.MoveCursorTop()
+	}
+
+	if ws != ctx.GetWorkspace() {
+		return
+	}
+
+	if ctx.View.Chat.Thread == "" {
+		actionChangeChannel(ctx)
+	} else {
+		render(ctx.View.Channels)
+	}
+}
+
+// actionSchedulerMetrics will periodically show the metrics of the
+// requests to slack in the Debug component, for every method that was
+// requested since the last time
+func actionSchedulerMetrics(ctx *context.AppContext) {
+	calls := make(map[string]int)
+
+	for range time.Tick(metricsInterval) {
+		for _, ws := range ctx.Workspaces {
+			var prefix string
+			if len(ctx.Workspaces) > 1 {
+				prefix = ws.Name + ": "
+			}
+
+			for _, m := range ws.Service.Scheduler.Metrics() {
+				if m.Calls == calls[prefix+m.Method] && m.Queued == 0 {
+					continue
+				}
+				calls[prefix+m.Method] = m.Calls
+
+				ctx.View.Debug.Println(
+					fmt.Sprintf(
+						"%s%s: %d calls, %d errors, %d rate limited, %d queued, wait avg %s max %s",
+						prefix, m.Method, m.Calls, m.Errors, m.RateLimited, m.Queued,
+						m.AverageWait().Round(time.Millisecond),
+						m.MaxWait.Round(time.Millisecond),
+					),
+				)
+			}
+		}
+	}
+}
+
+// actionScrollUpChat will scroll the Chat pane up, when we've reached the
+// top of the messages the older messages will be fetched
 func actionScrollUpChat(ctx *context.AppContext) {
+	if ctx.View.Chat.IsScrolledToTop() {
+		actionGetOlderMessages(ctx)
+	}
+
 	ctx.View.Chat.ScrollUp()
-	termui.Render(ctx.View.Chat)
+	render(ctx.View.Chat)
+}
+
+// actionGetOlderMessages will fetch a page of messages that were sent before
+// the oldest message in the Chat pane in the background, and add them to
+// the Chat pane
+func actionGetOlderMessages(ctx *context.AppContext) {
+	getOlderMessages(ctx, nil)
+}
+
+// getOlderMessages will fetch a page of older messages, loaded is executed
+// once they've been added, when it's set. It returns whether they're
+// fetched, which isn't the case when there are none or when they're
+// already being fetched.
+func getOlderMessages(ctx *context.AppContext, loaded func()) bool {
+	// Threads only show the replies of a single message
+	if ctx.Focus != context.ChatFocus || !ctx.View.Chat.HasMore || ctx.SearchResults != nil {
+		return false
+	}
+
+	latest := ctx.View.Chat.GetOldestMessageID()
+	if latest == "" {
+		return false
+	}
+
+	release, ok := startOlder()
+	if !ok {
+		return false
+	}
+
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+
+	var msgs []components.Message
+	var threads []components.ChannelItem
+	var hasMore bool
+
+	retry(
+		ctx,
+		"couldn't load the older messages",
+		func() error {
+			var err error
+			msgs, threads, hasMore, err = ctx.Service.GetOlderMessages(
+				channelID,
+				latest,
+				ctx.View.Chat.GetMaxItems(),
+			)
+			return err
+		},
+		func() {
+			// The next page can be loaded by loaded
+			release()
+
+			// A thread could've been opened, or the search results
+			// shown, in the meantime
+			if ctx.View.Chat.Thread != "" || ctx.SearchResults != nil ||
+				latest != ctx.View.Chat.GetOldestMessageID() {
+				return
+			}
+
+			addOlderMessages(ctx, msgs, threads, hasMore)
+
+			if loaded != nil {
+				loaded()
+			}
+		},
+		release,
+	)
+
+	return true
+}
+
+// addOlderMessages will add the older messages to the Chat pane, and their
+// threads to the Threads pane
+func addOlderMessages(ctx *context.AppContext, msgs []components.Message, threads []components.ChannelItem, hasMore bool) {
+	ctx.View.Chat.AddOlderMessages(msgs, hasMore)
+	actionLoadReplies(ctx)
+	render(ctx.View.Chat)
+
+	// Older threads are added after the ones that are already in the
+	// threads pane, when there weren't any the grid needs to be redrawn
+	if len(threads) > 0 {
+		if len(ctx.View.Threads.ChannelItems) > 0 {
+			ctx.View.Threads.SetChannels(
+				append(ctx.View.Threads.ChannelItems, threads...),
+			)
+			render(ctx.View.Threads)
+		} else {
+			ctx.View.Threads.SetChannels(
+				append(
+					[]components.ChannelItem{ctx.View.Channels.GetSelectedChannel()},
+					threads...,
+				),
+			)
+			ctx.View.Threads.MoveCursorTop()
+			actionRedrawGrid(ctx, true, ctx.Debug)
+		}
+	}
 }
 
+// actionScrollDownChat will scroll the Chat pane down, when we've reached
+// the bottom of a thread the next page of replies will be fetched
 func actionScrollDownChat(ctx *context.AppContext) {
+	if ctx.View.Chat.IsScrolledToBottom() {
+		actionGetReplies(ctx)
+	}
+
 	ctx.View.Chat.ScrollDown()
-	termui.Render(ctx.View.Chat)
+	render(ctx.View.Chat)
+}
+
+// actionSelectUpChat will move the message cursor in the Chat pane up, when
+// no message is selected the last message is selected
+func actionSelectUpChat(ctx *context.AppContext) {
+	// Fetch older messages when we're at the oldest message
+	selected := ctx.View.Chat.SelectedMessage
+	if selected != "" && selected == ctx.View.Chat.GetOldestMessageID() {
+		actionGetOlderMessages(ctx)
+	}
+
+	ctx.View.Chat.SelectPrevMessage()
+	render(ctx.View.Chat)
+}
+
+// actionSelectDownChat will move the message cursor in the Chat pane down,
+// moving past the last message removes the selection
+func actionSelectDownChat(ctx *context.AppContext) {
+	ctx.View.Chat.SelectNextMessage()
+	render(ctx.View.Chat)
+}
+
+func actionSelectClearChat(ctx *context.AppContext) {
+	ctx.View.Chat.SetSelectedMessage("")
+	render(ctx.View.Chat)
+}
+
+// getSelectedMessageFromUser returns the selected message when it was sent
+// by the current user, when no message is selected it returns the last
+// message of the current user
+func getSelectedMessageFromUser(ctx *context.AppContext) (components.Message, bool) {
+	if msg, ok := ctx.View.Chat.GetSelectedMessage(); ok {
+		return msg, msg.UserID == ctx.Service.CurrentUserID
+	}
+
+	msgs := ctx.View.Chat.GetMessagesFromUser(ctx.Service.CurrentUserID)
+	if len(msgs) == 0 {
+		return components.Message{}, false
+	}
+
+	return msgs[len(msgs)-1], true
+}
+
+// actionEditMessage will load the selected message of the current user into
+// the Input component, sending it will then update that message instead of
+// posting a new one. When none is selected the last message of the current
+// user is used.
+func actionEditMessage(ctx *context.AppContext) {
+	msg, ok := getSelectedMessageFromUser(ctx)
+	if !ok {
+		return
+	}
+
+	actionStartEdit(ctx, msg)
+}
+
+// actionEditPrevMessage will, when editing, move on to edit the previous
+// message of the current user. When not editing and the input is empty it
+// will start editing the last message.
+func actionEditPrevMessage(ctx *context.AppContext) {
+	if ctx.EditMessage == "" {
+		if ctx.View.Input.IsEmpty() {
+			actionEditMessage(ctx)
+		}
+		return
+	}
+
+	msgs := ctx.View.Chat.GetMessagesFromUser(ctx.Service.CurrentUserID)
+	for i, msg := range msgs {
+		if msg.ID == ctx.EditMessage && i > 0 {
+			actionStartEdit(ctx, msgs[i-1])
+			break
+		}
+	}
+}
+
+// actionEditNextMessage will, when editing, move on to edit the next
+// message of the current user. Moving past the last message will stop
+// editing.
+func actionEditNextMessage(ctx *context.AppContext) {
+	if ctx.EditMessage == "" {
+		return
+	}
+
+	msgs := ctx.View.Chat.GetMessagesFromUser(ctx.Service.CurrentUserID)
+	for i, msg := range msgs {
+		if msg.ID == ctx.EditMessage {
+			if i < len(msgs)-1 {
+				actionStartEdit(ctx, msgs[i+1])
+			} else {
+				actionCancelEdit(ctx)
+			}
+			break
+		}
+	}
+}
+
+func actionStartEdit(ctx *context.AppContext, msg components.Message) {
+	ctx.EditMessage = msg.ID
+	ctx.View.Chat.SetSelectedMessage(msg.ID)
+	ctx.View.Input.SetText(service.EditableText(msg.Text))
+
+	ctx.Mode = context.InsertMode
+	ctx.View.Mode.SetEditMode()
+
+	render(ctx.View.Chat, ctx.View.Input, ctx.View.Mode)
+}
+
+// actionCancelEdit will stop editing a message, and clear the input
+func actionCancelEdit(ctx *context.AppContext) {
+	if ctx.EditMessage == "" {
+		return
+	}
+
+	ctx.EditMessage = ""
+	ctx.View.Input.Clear()
+
+	if ctx.Mode == context.InsertMode {
+		ctx.View.Mode.SetInsertMode()
+	}
+
+	render(ctx.View.Chat, ctx.View.Input, ctx.View.Mode)
+}
+
+// actionDeleteMessage will ask for confirmation to delete the selected
+// message of the current user, when none is selected the last message of
+// the current user is used.
+func actionDeleteMessage(ctx *context.AppContext) {
+	msg, ok := getSelectedMessageFromUser(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.View.Chat.SetSelectedMessage(msg.ID)
+
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+	actionConfirm(ctx, "DELETE? y/n", func(ctx *context.AppContext) {
+		err := ctx.Service.DeleteMessage(channelID, msg.ID)
+		if err != nil {
+			actionError(ctx, err)
+		}
+	})
+}
+
+// actionRemoveMessage will remove a message from the Chat pane, when the
+// message was deleted
+func actionRemoveMessage(ctx *context.AppContext, ws *context.Workspace, channelID string, messageID string) {
+	if channelID != ws.View.Channels.ChannelItems[ws.View.Channels.SelectedChannel].ID {
+		return
+	}
+
+	if ctx.EditMessage == messageID && ws == ctx.GetWorkspace() {
+		actionCancelEdit(ctx)
+	}
+
+	ws.View.Chat.DeleteMessage(messageID)
+	renderWorkspace(ctx, ws, ws.View.Chat)
+}
+
+// actionConfirm will switch to the confirm mode and show the prompt in the
+// Mode component, the action is executed when the prompt is answered with
+// confirm-yes.
+func actionConfirm(ctx *context.AppContext, prompt string, action func(*context.AppContext)) {
+	ctx.Confirm = action
+	ctx.Mode = context.ConfirmMode
+	ctx.View.Mode.SetConfirmMode(prompt)
+
+	render(ctx.View.Chat, ctx.View.Mode)
+}
+
+func actionConfirmYes(ctx *context.AppContext) {
+	action := ctx.Confirm
+	actionConfirmNo(ctx)
+
+	if action != nil {
+		action(ctx)
+	}
+}
+
+func actionConfirmNo(ctx *context.AppContext) {
+	ctx.Confirm = nil
+	actionCommandMode(ctx)
+}
+
+// actionReact will switch to insert mode with the react command in the
+// input, the name of the emoji can then be typed and sent
+func actionReact(ctx *context.AppContext) {
+	actionInsertMode(ctx)
+
+	ctx.View.Input.SetText("/react :")
+	render(ctx.View.Input)
+}
+
+// actionToggleReaction will add the reaction to the selected message, or
+// remove it when the current user already reacted with it. When no message
+// is selected the last message in the Chat pane is used.
+func actionToggleReaction(ctx *context.AppContext, name string) {
+	msg, ok := ctx.View.Chat.GetSelectedMessage()
+	if !ok {
+		msgs := ctx.View.Chat.GetMessages()
+		for i := len(msgs) - 1; i >= 0; i-- {
+			// Attachments don't have a time, and can't be reacted on
+			if !msgs[i].Time.IsZero() {
+				msg, ok = msgs[i], true
+				break
+			}
+		}
+	}
+
+	if !ok {
+		return
+	}
+
+	channelID := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel].ID
+
+	var err error
+	if r, ok := msg.GetReaction(name); ok && r.HasUser(ctx.Service.CurrentUserID) {
+		err = ctx.Service.RemoveReaction(channelID, msg.ID, name)
+	} else {
+		err = ctx.Service.AddReaction(channelID, msg.ID, name)
+	}
+
+	if err != nil {
+		actionError(ctx, err)
+	}
 }
 
 func actionHelp(ctx *context.AppContext) {
 	ctx.View.Chat.ClearMessages()
 	ctx.View.Chat.Help(ctx.Usage, ctx.Config)
-	termui.Render(ctx.View.Chat)
+	render(ctx.View.Chat)
 }
 
 // GetKeyString will return a string that resembles the key event from
@@ -734,7 +1781,7 @@ func getKeyString(e termbox.Event) string {
 
 		if e.Key <= 0x7F {
 			pre = "C-"
-			k = string('a' - 1 + int(e.Key))
+			k = string(rune('a' - 1 + int(e.Key)))
 			kmap := map[termbox.Key][2]string{
 				termbox.KeyCtrlSpace:     {"C-", "<space>"},
 				termbox.KeyBackspace:     {"", "<backspace>"},
@@ -757,52 +1804,57 @@ func getKeyString(e termbox.Event) string {
 	return ek
 }
 
+// parseReactCommand will return the name of the emoji when the message is
+// a react command, e.g.:
+//
+//	/react :thumbsup:
+//	/react :+1::skin-tone-2:
+func parseReactCommand(message string) (string, bool) {
+	r := regexp.MustCompile(`^/react\s+:([\w+\-']+(::[\w\-]+)?):\s*$`)
+
+	rs := r.FindStringSubmatch(message)
+	if len(rs) < 2 {
+		return "", false
+	}
+
+	return rs[1], true
+}
+
 // isMention check if the message event either contains a
 // mention or is posted on an IM channel.
 func isMention(ctx *context.AppContext, ev *slack.MessageEvent) bool {
-	channel := ctx.View.Channels.ChannelItems[ctx.View.Channels.FindChannel(ev.Channel)]
-
-	if channel.Type == components.ChannelTypeIM {
+	channel, ok := ctx.View.Channels.GetChannel(ev.Channel)
+	if ok && channel.Type == components.ChannelTypeIM {
 		return true
 	}
 
-	// Mentions have the following format:
+	// Mentions of the user, of everyone in the channel and of the user
+	// groups the user is a member of have the following format:
 	//	<@U12345|erroneousboat>
 	// 	<@U12345>
-	r := regexp.MustCompile(`\<@(\w+\|*\w+)\>`)
-	matches := r.FindAllString(ev.Text, -1)
-	for _, match := range matches {
-		if strings.Contains(match, ctx.Service.CurrentUserID) {
-			return true
+	//	<!here>
+	//	<!channel>
+	//	<!everyone>
+	//	<!subteam^S12345|@team>
+	r := regexp.MustCompile(`\<([@!])([^>|]+)(\|[^>]*)?\>`)
+	for _, match := range r.FindAllStringSubmatch(ev.Text, -1) {
+		if match[1] == "@" {
+			if match[2] == ctx.Service.CurrentUserID {
+				return true
+			}
+			continue
 		}
-	}
-
-	return false
-}
 
-func createNotifyMessage(ctx *context.AppContext, ev *slack.MessageEvent) {
-	go func() {
-		if notifyTimer != nil {
-			notifyTimer.Stop()
-		}
-
-		// Only actually notify when time expires
-		notifyTimer = time.NewTimer(time.Second * 2)
-		<-notifyTimer.C
-
-		var message string
-		channel := ctx.View.Channels.ChannelItems[ctx.View.Channels.FindChannel(ev.Channel)]
-		switch channel.Type {
-		case components.ChannelTypeChannel:
-			message = fmt.Sprintf("Message received on channel: %s", channel.Name)
-		case components.ChannelTypeGroup:
-			message = fmt.Sprintf("Message received in group: %s", channel.Name)
-		case components.ChannelTypeIM:
-			message = fmt.Sprintf("Message received from: %s", channel.Name)
-		default:
-			message = fmt.Sprintf("Message received from: %s", channel.Name)
+		switch {
+		case match[2] == "here", match[2] == "channel", match[2] == "everyone":
+			return true
+		case strings.HasPrefix(match[2], "subteam^"):
+			if ctx.Service.IsUserGroupMember(strings.TrimPrefix(match[2], "subteam^")) {
+				return true
+			}
 		}
+	}
 
-		ctx.Notify.Push("slack-term", message, "", notificator.UR_NORMAL)
-	}()
+	// The highlight rules of the config
+	return ctx.Config.IsHighlight(html.UnescapeString(ev.Text))
 }
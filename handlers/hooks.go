@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/slack-go/slack"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/config"
+	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/hooks"
+	"github.com/erroneousboat/slack-term/service"
+)
+
+// eventHooks runs the commands of the hooks in the config, until they're
+// set by Initialize there are none
+var (
+	eventHooks = hooks.New(nil, nil)
+	hooksMutex sync.Mutex
+)
+
+// getEventHooks returns the eventHooks, they're used by the handlers that
+// run in the background as well
+func getEventHooks() *hooks.Hooks {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+
+	return eventHooks
+}
+
+// setEventHooks will replace the eventHooks
+func setEventHooks(h *hooks.Hooks) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+
+	eventHooks = h
+}
+
+// lastChannel is the workspace and the channel on which the channel_changed
+// hooks were last run, actionChangeChannel is also used to rerender the
+// channel that is shown
+var lastChannel string
+
+// filtering is set to 1 while the message_filter hooks run on a message that
+// is sent, the input is disabled until they're done
+var filtering int32
+
+// createHookEvent will create the event of the hooks for the channel, the
+// workspace is set when there are multiple
+func createHookEvent(ctx *context.AppContext, event string, channel components.ChannelItem) hooks.Event {
+	return hooks.Event{
+		Event:     event,
+		Workspace: getWorkspaceName(ctx),
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Type:      channel.Type,
+	}
+}
+
+// actionMessageHooks will run the message_received hooks for the new
+// message, and the mention hooks when the user is mentioned. The messages
+// of channels that aren't in the channels pane are skipped.
+func actionMessageHooks(ctx *context.AppContext, ev *slack.MessageEvent) {
+	switch ev.SubType {
+	case "message_changed", "message_deleted", "message_replied":
+		return
+	}
+
+	channel, ok := ctx.View.Channels.GetChannel(ev.Channel)
+	if !ok {
+		return
+	}
+
+	mention := isMention(ctx, ev)
+	h := getEventHooks()
+	if !h.Has(config.HookMessageReceived) && !(mention && h.Has(config.HookMention)) {
+		return
+	}
+
+	event := createHookEvent(ctx, config.HookMessageReceived, channel)
+	event.UserID = ev.User
+	event.Text = ev.Text
+	event.Timestamp = ev.Timestamp
+	event.Thread = ev.ThreadTimestamp
+
+	go func() {
+		if msg, err := ctx.Service.CreateMessageFromMessageEvent(ev, ev.Channel); err == nil {
+			event.User = msg.Name
+			event.Text = msg.Content
+		}
+
+		h.Run(event)
+
+		if mention {
+			event.Event = config.HookMention
+			h.Run(event)
+		}
+	}()
+}
+
+// actionSentHooks will run the message_sent hooks for the message that was
+// sent to the selected channel
+func actionSentHooks(ctx *context.AppContext, channel components.ChannelItem, message string, thread string) {
+	event := createHookEvent(ctx, config.HookMessageSent, channel)
+	event.UserID = ctx.Service.CurrentUserID
+	event.User = ctx.Service.CurrentUsername
+	event.Text = message
+	event.Thread = thread
+
+	getEventHooks().Run(event)
+}
+
+// actionChannelHooks will run the channel_changed hooks when another
+// channel is shown
+func actionChannelHooks(ctx *context.AppContext) {
+	channel := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel]
+
+	workspace := getWorkspaceName(ctx)
+	if workspace+"/"+channel.ID == lastChannel {
+		return
+	}
+	lastChannel = workspace + "/" + channel.ID
+
+	getEventHooks().Run(createHookEvent(ctx, config.HookChannelChanged, channel))
+}
+
+// isFiltering returns whether the message_filter hooks are running on a
+// message that is sent
+func isFiltering() bool {
+	return atomic.LoadInt32(&filtering) == 1
+}
+
+// actionFilterMessage will run the message_filter hooks on the message in
+// the background, and send the message that replaces it. The commands can
+// take up to ten seconds, the input is disabled until they're done. When
+// they fail the message is put back into the input, and when they remove
+// it nothing is sent.
+func actionFilterMessage(ctx *context.AppContext, svc *service.SlackService, channel components.ChannelItem, thread string, broadcast bool, message string) {
+	atomic.StoreInt32(&filtering, 1)
+	setStatus(ctx, "filtering the message", false, 0)
+
+	go func() {
+		filtered, err := filterMessage(ctx, channel, message, thread)
+
+		atomic.StoreInt32(&filtering, 0)
+		actionClearStatus(ctx)
+
+		if err != nil {
+			ctx.View.Input.SetText(message)
+			render(ctx.View.Input)
+			actionError(ctx, err)
+			return
+		}
+
+		if filtered != "" {
+			sendMessage(ctx, svc, channel, thread, broadcast, filtered)
+		}
+	}()
+}
+
+// filterMessage will run the message_filter hooks on the message that is
+// sent to the channel, it returns the message that replaces it
+func filterMessage(ctx *context.AppContext, channel components.ChannelItem, message string, thread string) (string, error) {
+	event := createHookEvent(ctx, config.HookMessageFilter, channel)
+	event.Text = message
+	event.Thread = thread
+
+	filtered, err := getEventHooks().Filter(event)
+	if err != nil {
+		return "", fmt.Errorf("couldn't filter the message: %s", err.Error())
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erroneousboat/termui"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/slack-go/slack"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/config"
+	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/hooks"
+	"github.com/erroneousboat/slack-term/service"
+	"github.com/erroneousboat/slack-term/slacktest"
+	"github.com/erroneousboat/slack-term/termtest"
+	"github.com/erroneousboat/slack-term/views"
+)
+
+// newTestContext returns the context of slack-term, connected to the
+// slacktest Server with the event source, and the screen the handlers
+// render on
+func newTestContext(t *testing.T, srv *slacktest.Server, eventSource string) (*context.AppContext, *termtest.Screen) {
+	t.Helper()
+
+	file, err := ioutil.TempFile("", "slack-term-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	fmt.Fprintf(file, `{
+		"slack_token": "xoxp-slacktest",
+		"app_token": "xapp-slacktest",
+		"api_url": %q,
+		"event_source": %q
+	}`, srv.URL(), eventSource)
+	file.Close()
+
+	cfg, err := config.NewConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Cache = false
+
+	workspace := cfg.GetWorkspaces()[0]
+	backend, err := service.NewBackend(service.BackendSlack, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := service.NewSlackService(cfg, workspace, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	screen := termtest.NewScreen(160, 30)
+	view, err := views.CreateViewWithHeight(cfg, svc, screen.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &context.AppContext{
+		Service:      svc,
+		View:         view,
+		Config:       cfg,
+		Mode:         context.CommandMode,
+		Focus:        context.ChatFocus,
+		SearchTarget: context.SearchChannels,
+		Workspaces: []*context.Workspace{
+			{Name: svc.CurrentTeam, Service: svc, View: view},
+		},
+	}
+
+	// The handlers of the previous tests keep running, only the
+	// components of this view are drawn on the screen
+	shown := map[termui.Bufferer]bool{
+		view.Input: true, view.Chat: true, view.Channels: true,
+		view.Threads: true, view.Mode: true, view.Debug: true,
+	}
+	render = func(bs ...termui.Bufferer) {
+		for _, b := range bs {
+			if _, ok := b.(*termui.Grid); ok || shown[b] {
+				screen.Render(b)
+			}
+		}
+	}
+
+	screen.Layout(view, len(view.Threads.ChannelItems) > 0, false)
+
+	return ctx, screen
+}
+
+// waitForConnection waits until the websocket of the workspace has been
+// connected
+func waitForConnection(t *testing.T, workspace *service.FakeBackend) {
+	t.Helper()
+
+	for start := time.Now(); !workspace.IsConnected(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("the websocket wasn't connected")
+		}
+	}
+}
+
+// waitForScreen waits until the line of the screen that contains the text
+// is rendered, and returns it
+func waitForScreen(t *testing.T, screen *termtest.Screen, text string) string {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		for _, line := range strings.Split(screen.String(), "\n") {
+			if strings.Contains(line, text) {
+				return line
+			}
+		}
+	}
+
+	t.Fatalf("%q wasn't rendered:\n%s", text, screen.String())
+	return ""
+}
+
+func TestMessageHandler(t *testing.T) {
+	for _, eventSource := range []string{config.EventSourceRTM, config.EventSourceSocketMode} {
+		t.Run(eventSource, func(t *testing.T) {
+			workspace := service.NewFakeBackend()
+			workspace.Script = nil
+
+			srv := slacktest.NewServer(workspace)
+			defer srv.Close()
+
+			ctx, screen := newTestContext(t, srv, eventSource)
+			messageHandler(ctx)
+			waitForConnection(t, workspace)
+
+			// A message in the channel that is shown is added to the
+			// Chat pane
+			msg := workspace.AddMessage("C00000001", "U00000002", "Hello over the websocket", "")
+			ev := slack.MessageEvent(msg)
+			workspace.Emit(slack.RTMEvent{Type: "message", Data: &ev})
+
+			line := waitForScreen(t, screen, "Hello over the websocket")
+			if !strings.Contains(line, "<alice>") {
+				t.Errorf("expected the message of alice, got %q", line)
+			}
+
+			// A message in another channel marks it as unread
+			msg = workspace.AddMessage("C00000002", "U00000003", "Elsewhere", "")
+			ev = slack.MessageEvent(msg)
+			workspace.Emit(slack.RTMEvent{Type: "message", Data: &ev})
+
+			waitForScreen(t, screen, components.IconNotification+" "+components.IconChannel+" random")
+			if strings.Contains(screen.String(), "Elsewhere") {
+				t.Error("the message of another channel was added to the Chat pane")
+			}
+
+			// A message that is sent over http comes back over the
+			// websocket
+			if err := ctx.Service.SendMessage("C00000001", "Hello over http"); err != nil {
+				t.Fatal(err)
+			}
+
+			line = waitForScreen(t, screen, "Hello over http")
+			if !strings.Contains(line, "<slack-term>") {
+				t.Errorf("expected the message of slack-term, got %q", line)
+			}
+
+			if history, _ := workspace.GetConversationHistory(&slack.GetConversationHistoryParameters{
+				ChannelID: "C00000001",
+				Limit:     1,
+			}); history == nil || history.Messages[0].Text != "Hello over http" {
+				t.Errorf("the message wasn't posted to the workspace")
+			}
+		})
+	}
+}
+
+func TestFilterMessage(t *testing.T) {
+	workspace := service.NewFakeBackend()
+	workspace.Script = nil
+
+	srv := slacktest.NewServer(workspace)
+	defer srv.Close()
+
+	ctx, screen := newTestContext(t, srv, config.EventSourceRTM)
+	messageHandler(ctx)
+	waitForConnection(t, workspace)
+
+	previous := getEventHooks()
+	setEventHooks(hooks.New(map[string][]string{
+		config.HookMessageFilter: {"sleep 0.5; tr a-z A-Z"},
+	}, nil))
+	t.Cleanup(func() { setEventHooks(previous) })
+
+	ctx.Mode = context.InsertMode
+	ctx.View.Input.SetText("hello filter")
+	actionSend(ctx)
+
+	// The input is disabled while the filter runs
+	if !isFiltering() {
+		t.Fatal("the message isn't filtered in the background")
+	}
+	actionKeyEvent(ctx, termbox.Event{Type: termbox.EventKey, Ch: 'x'})
+	if text := ctx.View.Input.GetText(); text != "" {
+		t.Errorf("the input was changed while filtering: %q", text)
+	}
+
+	waitForScreen(t, screen, "HELLO FILTER")
+	if isFiltering() {
+		t.Error("the input is still disabled after the message was sent")
+	}
+
+	// When the filters remove the message nothing is sent
+	setEventHooks(hooks.New(map[string][]string{
+		config.HookMessageFilter: {"cat >/dev/null"},
+	}, nil))
+
+	ctx.View.Input.SetText("never sent")
+	actionSend(ctx)
+
+	for start := time.Now(); isFiltering(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("the message is still filtered")
+		}
+	}
+
+	if history, _ := workspace.GetConversationHistory(&slack.GetConversationHistoryParameters{
+		ChannelID: "C00000001",
+		Limit:     1,
+	}); history == nil || history.Messages[0].Text != "HELLO FILTER" {
+		t.Errorf("the message that was removed by the filters was sent")
+	}
+}
+
+// heldBackend holds the requests for the history of a channel until they're
+// released, and counts them
+type heldBackend struct {
+	service.Backend
+
+	requests chan struct{}
+	release  chan struct{}
+}
+
+func (b *heldBackend) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	b.requests <- struct{}{}
+	<-b.release
+	return b.Backend.GetConversationHistory(params)
+}
+
+func TestGetOlderMessagesOnce(t *testing.T) {
+	workspace := service.NewFakeBackend()
+	workspace.Script = nil
+
+	srv := slacktest.NewServer(workspace)
+	defer srv.Close()
+
+	ctx, screen := newTestContext(t, srv, config.EventSourceRTM)
+
+	held := &heldBackend{
+		Backend:  ctx.Service.Client,
+		requests: make(chan struct{}, 10),
+		release:  make(chan struct{}),
+	}
+	ctx.Service.Client = held
+
+	ctx.View.Chat.SetMessages([]components.Message{
+		{ID: "9999999999.000000", Time: time.Now(), Content: "The newest message"},
+	})
+	ctx.View.Chat.HasMore = true
+	render(ctx.View.Chat)
+
+	// The requests of the previous tests are stopped
+	stopChannel()
+
+	// Scrolling up again while the older messages are fetched doesn't
+	// fetch them twice
+	actionGetOlderMessages(ctx)
+	actionGetOlderMessages(ctx)
+
+	<-held.requests
+	select {
+	case <-held.requests:
+		t.Fatal("the older messages were fetched twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if strings.Contains(screen.String(), "Good morning everyone!") {
+		t.Fatal("the older messages were added before they were fetched")
+	}
+
+	close(held.release)
+	waitForScreen(t, screen, "Good morning everyone!")
+
+	// Once they've been added the next page can be fetched
+	release, ok := startOlder()
+	if !ok {
+		t.Fatal("the next page of older messages can't be fetched")
+	}
+	release()
+}
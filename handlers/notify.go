@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/config"
+	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/notify"
+	"github.com/erroneousboat/slack-term/service"
+)
+
+// actionLoadNotificationPrefs will get the muted channels and the
+// notification levels of the channels from the preferences of the user in
+// slack, and set them on the channels of the workspace
+func actionLoadNotificationPrefs(ctx *context.AppContext, ws *context.Workspace) {
+	if err := ws.Service.LoadNotificationPrefs(); err != nil {
+		actionError(ctx, fmt.Errorf("couldn't load the notification preferences: %s", err.Error()))
+		return
+	}
+
+	for _, chn := range ws.View.Channels.ChannelItems {
+		muted, level := ws.Service.GetNotificationPrefs(chn.ID)
+		ws.View.Channels.SetNotificationPrefs(chn.ID, muted, level)
+	}
+
+	if ws == ctx.GetWorkspace() {
+		render(ctx.View.Channels)
+	}
+}
+
+// actionLoadUserGroups will get the user groups of the workspace, the
+// mentions of the user groups the user is a member of are highlighted
+func actionLoadUserGroups(ctx *context.AppContext, ws *context.Workspace) {
+	if err := ws.Service.LoadUserGroups(); err != nil {
+		actionError(ctx, fmt.Errorf("couldn't load the user groups: %s", err.Error()))
+		return
+	}
+
+	ws.View.Chat.Highlights = ws.Service.GetHighlights()
+
+	if ws == ctx.GetWorkspace() {
+		render(ctx.View.Chat)
+	}
+}
+
+// getLevel returns the notification level of the channel of the workspace of
+// the service, the one in the config overrides the one in slack. Muted channels aren't
+// notified, and the other channels are notified of every message by
+// default.
+func getLevel(svc *service.SlackService, channel components.ChannelItem) string {
+	workspace := svc.Workspace
+	if level := workspace.GetLevel(channel.ID, channel.Name); level != "" {
+		return level
+	}
+
+	if channel.Muted || workspace.Sidebar.IsMuted(channel.ID, channel.Name) {
+		return config.LevelNothing
+	}
+
+	if channel.Level != "" {
+		return channel.Level
+	}
+
+	return config.LevelAll
+}
+
+// addUnread will count the new message in its channel and mark the channel
+// as unread. When the channel isn't notified at all it is only marked when
+// the user is mentioned. It returns whether the channel was marked, which
+// isn't the case when the channel isn't in the channels pane.
+func addUnread(ctx *context.AppContext, ev *slack.MessageEvent) bool {
+	channel, ok := ctx.View.Channels.GetChannel(ev.Channel)
+	if !ok {
+		return false
+	}
+
+	mention := isMention(ctx, ev)
+	if !mention && getLevel(ctx.Service, channel) == config.LevelNothing {
+		return false
+	}
+
+	ctx.View.Channels.AddUnread(ev.Channel, mention, time.Now())
+	return true
+}
+
+// createNotification will create the notification of the message, with a
+// preview of its text. The workspace is set when there are multiple.
+func createNotification(ctx *context.AppContext, channel components.ChannelItem, ev *slack.MessageEvent, mention bool) notify.Notification {
+	notification := notify.Notification{
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Type:      channel.Type,
+		Text:      ev.Text,
+		Mention:   mention,
+		Count:     1,
+	}
+
+	if msg, err := ctx.Service.CreateMessageFromMessageEvent(ev, ev.Channel); err == nil {
+		notification.User = msg.Name
+		notification.Text = msg.Content
+	}
+
+	notification.Workspace = getWorkspaceName(ctx)
+
+	return notification
+}
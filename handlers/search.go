@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/service"
+)
+
+const (
+	// searchCount is the maximum amount of messages that are found by a
+	// search
+	searchCount = 50
+
+	// searchMaxPages is the maximum amount of pages of older messages, or
+	// of replies, that are fetched to find the message of a search result
+	searchMaxPages = 10
+
+	// searchTimeFormat is the format of the time of the search results,
+	// they can be from any day
+	searchTimeFormat = "2006-01-02 15:04"
+)
+
+// actionToggleSearchTarget will switch between searching the channels,
+// the messages and the users
+func actionToggleSearchTarget(ctx *context.AppContext) {
+	switch ctx.SearchTarget {
+	case context.SearchChannels:
+		ctx.SearchTarget = context.SearchMessages
+	case context.SearchMessages:
+		ctx.SearchTarget = context.SearchUsers
+	default:
+		ctx.SearchTarget = context.SearchChannels
+	}
+
+	actionSearchMode(ctx)
+}
+
+// actionSubmitSearch will search the messages or the users for the input,
+// when they're searched. The channels are searched while typing.
+func actionSubmitSearch(ctx *context.AppContext) {
+	query := ctx.View.Input.GetText()
+	actionClearInput(ctx)
+
+	if query == "" {
+		return
+	}
+
+	switch ctx.SearchTarget {
+	case context.SearchMessages:
+		actionSearchMessages(ctx, query)
+	case context.SearchUsers:
+		actionSearchUsers(ctx, query)
+	}
+}
+
+// actionSearchMessages will search the messages with slack, when it can't
+// be reached the cached messages are searched instead
+func actionSearchMessages(ctx *context.AppContext, query string) {
+	if ctx.GetWorkspace().Connection != components.ConnectionOffline {
+		results, err := ctx.Service.SearchMessages(query, searchCount)
+		if err == nil {
+			actionShowSearchResults(ctx, query, results, false)
+			return
+		}
+
+		if ctx.Service.Cache == nil {
+			actionError(ctx, fmt.Errorf("couldn't search the messages: %s", err.Error()))
+			return
+		}
+
+		actionError(ctx, fmt.Errorf("couldn't search the messages, searched the cached messages: %s", err.Error()))
+	} else if ctx.Service.Cache == nil {
+		actionError(ctx, errors.New("the messages can't be searched while offline without the cache"))
+		return
+	}
+
+	results := ctx.Service.SearchCachedMessages(query, searchCount)
+	actionShowSearchResults(ctx, query, results, true)
+}
+
+// actionShowSearchResults will show the messages that were found in the
+// Chat pane, with the channel and the day on which they were sent. The
+// newest message is selected, so that it can be opened right away.
+func actionShowSearchResults(ctx *context.AppContext, query string, results []service.SearchResult, cached bool) {
+	var newest string
+	msgs := make([]components.Message, len(results))
+	for i, result := range results {
+		msg := result.Message
+
+		// The results are from several channels, the ID in the Chat pane
+		// still sorts them by the time they were sent. The ID of the
+		// result is kept to find the message in its channel.
+		msg.ID = msg.ID + result.ChannelID
+		msg.FormatTime = searchTimeFormat
+
+		index := ctx.View.Channels.FindChannel(result.ChannelID)
+		if index >= 0 {
+			channel := ctx.View.Channels.ChannelItems[index]
+			msg.Thread = fmt.Sprintf("%s %s ", channel.GetIcon(), channel.Name)
+		} else {
+			msg.Thread = result.ChannelID + " "
+		}
+
+		msgs[i] = msg
+
+		if msg.ID > newest {
+			newest = msg.ID
+		}
+	}
+
+	label := fmt.Sprintf("Search: %s (%d)", query, len(results))
+	if cached {
+		label = fmt.Sprintf("Search cached messages: %s (%d)", query, len(results))
+	}
+
+	actionSetSearchResults(ctx, label, results, msgs, newest)
+}
+
+// actionSearchUsers will show the users of which one of the names matches
+// the query in the Chat pane, the conversation with the selected user can
+// be opened
+func actionSearchUsers(ctx *context.AppContext, query string) {
+	users := ctx.Service.SearchUsers(query)
+
+	var first string
+	results := make([]service.SearchResult, 0, len(users))
+	msgs := make([]components.Message, 0, len(users))
+	for _, user := range users {
+		content := user.Name
+		if user.RealName != "" {
+			content = fmt.Sprintf("%s  %s", content, user.RealName)
+		}
+		if user.DisplayName != "" && user.DisplayName != user.Name {
+			content = fmt.Sprintf("%s (%s)", content, user.DisplayName)
+		}
+
+		// The ID sorts the users by their name
+		msg := components.Message{
+			ID:         user.Name + user.ID,
+			UserID:     user.ID,
+			Content:    content,
+			Selectable: true,
+		}
+
+		results = append(results, service.SearchResult{
+			UserID:  user.ID,
+			Message: msg,
+		})
+		msgs = append(msgs, msg)
+
+		if first == "" || msg.ID < first {
+			first = msg.ID
+		}
+	}
+
+	label := fmt.Sprintf("Search users: %s (%d)", query, len(results))
+	actionSetSearchResults(ctx, label, results, msgs, first)
+}
+
+// actionSetSearchResults will replace the messages of the Chat pane with
+// the search results, and select one of them. Every result is shown as the
+// message at the same index of msgs, by the ID of which it can be found in
+// the SearchResults of the context.
+func actionSetSearchResults(ctx *context.AppContext, label string, results []service.SearchResult, msgs []components.Message, selected string) {
+	actionCancelEdit(ctx)
+	actionSetBroadcast(ctx, false)
+
+	wasThread := ctx.View.Chat.Thread != ""
+
+	ctx.View.Chat.ClearMessages()
+	ctx.View.Chat.HasMore = false
+	ctx.Focus = context.ChatFocus
+
+	ctx.SearchResults = make(map[string]service.SearchResult)
+	for i, result := range results {
+		ctx.View.Chat.AddMessage(msgs[i])
+		ctx.SearchResults[msgs[i].ID] = result
+	}
+
+	ctx.View.Chat.SetBorderLabel(label)
+	ctx.View.Chat.SetSelectedMessage(selected)
+
+	if wasThread {
+		actionRedrawGrid(ctx, len(ctx.View.Threads.ChannelItems) > 0, ctx.Debug)
+	} else {
+		render(ctx.View.Chat)
+	}
+}
+
+// actionOpenSearchResult will show the selected message of the search
+// results in its channel, or in its thread when it is a reply. For a user
+// the direct message conversation is opened, and a channel of the channel
+// browser is joined.
+func actionOpenSearchResult(ctx *context.AppContext) {
+	msg, ok := ctx.View.Chat.GetSelectedMessage()
+	if !ok {
+		return
+	}
+
+	result, ok := ctx.SearchResults[msg.ID]
+	if !ok {
+		return
+	}
+
+	if result.UserID != "" {
+		actionOpenIM(ctx, result.UserID)
+		return
+	}
+
+	if result.Channel != nil {
+		actionJoinChannel(ctx, result.ChannelID)
+		return
+	}
+
+	index := ctx.View.Channels.FindChannel(result.ChannelID)
+	if index < 0 {
+		actionError(ctx, errors.New("the channel of the message isn't in the channels pane"))
+		return
+	}
+
+	ctx.View.Channels.GotoPosition(index)
+
+	// The message is looked for once the messages of the channel, or the
+	// replies of the thread, have been loaded
+	if result.ThreadID != "" {
+		actionChangeChannel(ctx)
+		openThread(ctx, result.ThreadID, func() {
+			// The reply can be on one of the next pages of the thread
+			for i := 0; i < searchMaxPages && ctx.View.Chat.ThreadCursor != ""; i++ {
+				if _, ok := ctx.View.Chat.GetMessage(result.Message.ID); ok {
+					break
+				}
+				actionGetReplies(ctx)
+			}
+
+			selectSearchResult(ctx, result)
+		})
+		return
+	}
+
+	changeChannel(ctx, func() {
+		findOlderSearchResult(ctx, result, 0)
+	})
+}
+
+// findOlderSearchResult will select the message of the search result, when
+// it isn't in the Chat pane the next page of older messages is fetched in
+// the background, up to searchMaxPages pages
+func findOlderSearchResult(ctx *context.AppContext, result service.SearchResult, page int) {
+	if _, ok := ctx.View.Chat.GetMessage(result.Message.ID); ok || page >= searchMaxPages {
+		selectSearchResult(ctx, result)
+		return
+	}
+
+	fetched := getOlderMessages(ctx, func() {
+		findOlderSearchResult(ctx, result, page+1)
+	})
+	if !fetched {
+		selectSearchResult(ctx, result)
+	}
+}
+
+// selectSearchResult will select the message of the search result, when it
+// has been found in the Chat pane
+func selectSearchResult(ctx *context.AppContext, result service.SearchResult) {
+	if _, ok := ctx.View.Chat.GetMessage(result.Message.ID); ok {
+		ctx.View.Chat.SetSelectedMessage(result.Message.ID)
+	}
+	render(ctx.View.Chat)
+}
+
+// actionOpenIM will show the direct message conversation with the user,
+// when it isn't in the channels pane it is opened with slack and added
+func actionOpenIM(ctx *context.AppContext, userID string) {
+	index := -1
+	for i, channel := range ctx.View.Channels.ChannelItems {
+		if channel.Type == components.ChannelTypeIM && channel.UserID == userID {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		item, err := ctx.Service.OpenIM(userID)
+		if err != nil {
+			actionError(ctx, fmt.Errorf("couldn't open the conversation: %s", err.Error()))
+			return
+		}
+
+		index = ctx.View.Channels.AddChannel(item)
+
+		ws := ctx.GetWorkspace()
+		go func() {
+			presence, err := ws.Service.GetUserPresence(userID)
+			if err != nil {
+				return
+			}
+
+			ws.View.Channels.SetPresence(item.ID, presence)
+			if ws == ctx.GetWorkspace() {
+				render(ctx.View.Channels)
+			}
+		}()
+	}
+
+	ctx.View.Channels.GotoPosition(index)
+	actionChangeChannel(ctx)
+}
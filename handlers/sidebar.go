@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/erroneousboat/slack-term/context"
+)
+
+// actionToggleFavourite will add the selected channel to the favourites, or
+// remove it from them, the changes to the favourites of the workspace are
+// saved apart from the config file
+func actionToggleFavourite(ctx *context.AppContext) {
+	channel := ctx.View.Channels.GetSelectedChannel()
+
+	ctx.Service.Workspace.Sidebar.ToggleFavourite(channel.ID, channel.Name)
+	render(ctx.View.Channels)
+
+	if err := ctx.Config.SaveSidebar(); err != nil {
+		actionError(ctx, fmt.Errorf("couldn't save the favourites: %s", err.Error()))
+	}
+}
+
+// actionToggleSection will collapse the section of the selected channel, or
+// expand it when it is collapsed. A collapsed section only shows the
+// selected channel and the channels with unread messages.
+func actionToggleSection(ctx *context.AppContext) {
+	section := ctx.View.Channels.GetSelectedSection()
+	if section == "" {
+		return
+	}
+
+	ctx.Service.Workspace.Sidebar.ToggleCollapsed(section)
+	render(ctx.View.Channels)
+
+	if err := ctx.Config.SaveSidebar(); err != nil {
+		actionError(ctx, fmt.Errorf("couldn't save the sections: %s", err.Error()))
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erroneousboat/slack-term/context"
+	"github.com/erroneousboat/slack-term/service"
+)
+
+const (
+	// statusTimeout is the duration a status is shown
+	statusTimeout = 5 * time.Second
+
+	// maxRetries is the amount of times a failed request is retried, the
+	// time between the retries starts at minBackoff and doubles up to
+	// maxBackoff
+	maxRetries = 5
+	minBackoff = 1 * time.Second
+	maxBackoff = 30 * time.Second
+)
+
+var (
+	// statusID identifies the last status that was set, so that the timer
+	// of a previous status won't remove it
+	statusID    int
+	statusMutex sync.Mutex
+)
+
+// actionError will show the error in the status of the Input component,
+// and add it to the Debug component
+func actionError(ctx *context.AppContext, err error) {
+	ctx.View.Debug.Println(
+		err.Error(),
+	)
+
+	setStatus(ctx, err.Error(), true, statusTimeout)
+}
+
+// actionStatus will show the text in the status of the Input component
+func actionStatus(ctx *context.AppContext, text string) {
+	setStatus(ctx, text, false, statusTimeout)
+}
+
+// actionClearStatus will remove the status from the Input component
+func actionClearStatus(ctx *context.AppContext) {
+	setStatus(ctx, "", false, 0)
+}
+
+// setStatus will set the status of the Input component, the status is
+// removed after the timeout, unless it is 0 or a new status was set
+func setStatus(ctx *context.AppContext, text string, isError bool, timeout time.Duration) {
+	statusMutex.Lock()
+	statusID++
+	id := statusID
+	ctx.View.Input.SetStatus(text, isError)
+	statusMutex.Unlock()
+
+	render(ctx.View.Input)
+
+	if text == "" || timeout == 0 {
+		return
+	}
+
+	time.AfterFunc(timeout, func() {
+		statusMutex.Lock()
+		if id != statusID {
+			statusMutex.Unlock()
+			return
+		}
+		ctx.View.Input.SetStatus("", false)
+		statusMutex.Unlock()
+
+		render(ctx.View.Input)
+	})
+}
+
+// actionRetry will execute the request fn in the background and, when it
+// succeeds, apply its result with apply. When the request fails the error
+// is shown, and when the error is retryable, e.g. a server error or a rate
+// limit, the request is retried with an exponential backoff.
+//
+// Only use this for requests that are safe to repeat. The request and the
+// retries are stopped when another channel is shown, but apply is still
+// executed after the user moved on, e.g. to a thread, so it needs to check
+// whether the result is still relevant.
+func actionRetry(ctx *context.AppContext, description string, fn func() error, apply func()) {
+	retry(ctx, description, fn, apply, nil)
+}
+
+// retry is actionRetry, finished is executed when the request has been
+// applied, has failed for good, or was stopped, when it's set
+func retry(ctx *context.AppContext, description string, fn func() error, apply func(), finished func()) {
+	done := doneChannel()
+
+	// The result is dropped when another channel is shown
+	applyShown := func() {
+		select {
+		case <-done:
+		default:
+			apply()
+		}
+	}
+
+	go func() {
+		if finished != nil {
+			defer finished()
+		}
+
+		err := fn()
+		if err == nil {
+			applyShown()
+			return
+		}
+
+		actionError(ctx, fmt.Errorf("%s: %s", description, err.Error()))
+
+		if !service.IsRetryable(err) {
+			return
+		}
+
+		backoff := minBackoff
+		for attempt := 1; attempt <= maxRetries; attempt++ {
+			wait := backoff
+			if retryAfter := service.RetryAfter(err); retryAfter > wait {
+				wait = retryAfter
+			}
+
+			setStatus(
+				ctx,
+				fmt.Sprintf("%s, retrying in %s (%d/%d)", description, wait, attempt, maxRetries),
+				true, 0,
+			)
+
+			select {
+			case <-time.After(wait):
+			case <-done:
+				actionClearStatus(ctx)
+				return
+			}
+
+			err = fn()
+			if err == nil {
+				actionClearStatus(ctx)
+				applyShown()
+				return
+			}
+
+			ctx.View.Debug.Println(
+				err.Error(),
+			)
+
+			if !service.IsRetryable(err) {
+				break
+			}
+
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		actionError(ctx, fmt.Errorf("%s: %s", description, err.Error()))
+	}()
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/erroneousboat/termui"
+
+	"github.com/erroneousboat/slack-term/context"
+)
+
+// render will draw the components on the terminal, the tests draw them on
+// a termtest.Screen instead
+var render = termui.Render
+
+// renderWorkspace will render the components of the workspace when it is
+// the selected one, the components of the other workspaces are rendered
+// once they're selected
+func renderWorkspace(ctx *context.AppContext, ws *context.Workspace, bs ...termui.Bufferer) {
+	if ws == ctx.GetWorkspace() {
+		render(bs...)
+	}
+}
+
+// clearTerm will clear the terminal before the grid is drawn anew
+var clearTerm = termui.Clear
+
+// termSize returns the width and the height of the terminal
+var termSize = func() (int, int) {
+	return termui.TermWidth(), termui.TermHeight()
+}
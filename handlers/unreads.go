@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/config"
+	"github.com/erroneousboat/slack-term/context"
+)
+
+// unread is a channel with unread messages, in the workspace at the index
+type unread struct {
+	workspace int
+	channel   components.ChannelItem
+}
+
+// getUnreads returns the channels with unread messages of all workspaces,
+// the channel with the most recent message first
+func getUnreads(ctx *context.AppContext) []unread {
+	var unreads []unread
+	for i, ws := range ctx.Workspaces {
+		for _, chn := range ws.View.Channels.ChannelItems {
+			if chn.Notification {
+				unreads = append(unreads, unread{workspace: i, channel: chn})
+			}
+		}
+	}
+
+	sort.SliceStable(unreads, func(i, j int) bool {
+		return unreads[i].channel.Latest.After(unreads[j].channel.Latest)
+	})
+
+	return unreads
+}
+
+// actionSetUnreads will set the channels with unread messages in the
+// Unreads section of the Channels pane, when it is enabled
+func actionSetUnreads(ctx *context.AppContext) {
+	if !ctx.Config.Unreads {
+		return
+	}
+
+	var items []components.UnreadItem
+	for _, u := range getUnreads(ctx) {
+		item := components.UnreadItem{Channel: u.channel}
+		if len(ctx.Workspaces) > 1 {
+			item.Workspace = ctx.Workspaces[u.workspace].Name
+		}
+		items = append(items, item)
+	}
+
+	ctx.View.Channels.SetUnreads(items)
+	render(ctx.View.Channels)
+}
+
+// actionLoadUnreads will get the amount of unread messages, and the time of
+// the latest message, of every channel of the workspace. These requests are
+// rate limited, they are spread out by the scheduler of the service. The
+// channel that is shown isn't marked, its messages are read.
+func actionLoadUnreads(ctx *context.AppContext, ws *context.Workspace) {
+	for _, chn := range ws.View.Channels.ChannelItems {
+		unread, err := ws.Service.GetUnread(chn.ID)
+		if err != nil {
+			// The other channels would most likely fail the same way
+			actionError(ctx, fmt.Errorf("couldn't check channel %s for unread messages: %s", chn.Name, err.Error()))
+			return
+		}
+
+		// Whether the unread messages mention the user isn't known,
+		// they aren't marked when the channel isn't notified at all
+		isUnread := unread.Unread && getLevel(ws.Service, chn) != config.LevelNothing &&
+			chn.ID != ws.View.Channels.GetSelectedChannel().ID
+
+		ws.View.Channels.SetUnread(chn.ID, isUnread, unread.Count, unread.Latest)
+		actionSetUnreads(ctx)
+
+		// The channels can be sorted by their latest message
+		if ws == ctx.GetWorkspace() {
+			render(ctx.View.Channels)
+		}
+	}
+}
+
+// actionJumpUnread will select the channel with the most recent unread
+// message, switching to its workspace when necessary
+func actionJumpUnread(ctx *context.AppContext) {
+	selected := ctx.View.Channels.GetSelectedChannel()
+
+	for _, u := range getUnreads(ctx) {
+		if u.workspace == ctx.SelectedWorkspace && u.channel.ID == selected.ID {
+			continue
+		}
+
+		index := ctx.Workspaces[u.workspace].View.Channels.FindChannel(u.channel.ID)
+		if index < 0 {
+			continue
+		}
+
+		if u.workspace == ctx.SelectedWorkspace {
+			ctx.View.Channels.GotoPosition(index)
+			actionChangeChannel(ctx)
+			return
+		}
+
+		// The channel is loaded after the workspace is selected, the
+		// whole view has changed so the grid is redrawn
+		selectWorkspace(ctx, u.workspace)
+		ctx.View.Channels.GotoPosition(index)
+		actionChangeChannel(ctx)
+		actionSetWorkspaceLabel(ctx)
+		actionSetUnreads(ctx)
+		actionRedrawGrid(ctx, len(ctx.View.Threads.ChannelItems) > 0, ctx.Debug)
+		return
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/context"
+)
+
+// actionNextWorkspace will select the next workspace
+func actionNextWorkspace(ctx *context.AppContext) {
+	actionSwitchWorkspace(
+		ctx, (ctx.SelectedWorkspace+1)%len(ctx.Workspaces),
+	)
+}
+
+// actionPrevWorkspace will select the previous workspace
+func actionPrevWorkspace(ctx *context.AppContext) {
+	actionSwitchWorkspace(
+		ctx, (ctx.SelectedWorkspace+len(ctx.Workspaces)-1)%len(ctx.Workspaces),
+	)
+}
+
+// actionSwitchWorkspace will select the workspace at the index, and show
+// its Channels, Chat and Threads
+func actionSwitchWorkspace(ctx *context.AppContext, index int) {
+	if index == ctx.SelectedWorkspace {
+		return
+	}
+
+	selectWorkspace(ctx, index)
+
+	// The messages that were received while the workspace wasn't selected
+	// haven't been added to the Chat pane
+	if ctx.View.Chat.Thread == "" {
+		actionGetMessages(ctx)
+	}
+
+	// Clear notification icon if there is any
+	channelItem := ctx.View.Channels.ChannelItems[ctx.View.Channels.SelectedChannel]
+	if channelItem.Notification {
+		ctx.Service.MarkAsRead(channelItem)
+		ctx.View.Channels.MarkAsRead(ctx.View.Channels.SelectedChannel)
+	}
+
+	actionSetWorkspaceLabel(ctx)
+	actionSetUnreads(ctx)
+	actionRedrawGrid(ctx, len(ctx.View.Threads.ChannelItems) > 0, ctx.Debug)
+}
+
+// selectWorkspace will make the workspace at the index the one that is
+// used by the actions, nothing is loaded or rendered
+func selectWorkspace(ctx *context.AppContext, index int) {
+	ctx.GetWorkspace().Focus = ctx.Focus
+
+	// The replies and messages of the channel of this workspace are loaded
+	// again when we return
+	stopChannel()
+
+	// The search results are only kept while they're shown, the messages
+	// of the channel are loaded when we return
+	if ctx.SearchResults != nil {
+		ctx.SearchResults = nil
+		ctx.View.Chat.ClearMessages()
+		ctx.View.Chat.SetBorderLabel(
+			ctx.View.Channels.GetSelectedChannel().GetChannelName(),
+		)
+	}
+
+	// The input is shared between the workspaces, what was typed was
+	// meant for the workspace we're leaving
+	ctx.EditMessage = ""
+	ctx.Broadcast = false
+	ctx.View.Input.Clear()
+	ctx.View.Input.SetBorderLabel("")
+
+	ctx.SelectedWorkspace = index
+
+	ws := ctx.GetWorkspace()
+	ws.Unread = false
+
+	ctx.Service = ws.Service
+	ctx.View = ws.View
+	ctx.Focus = ws.Focus
+	ctx.View.Mode.SetConnection(ws.Connection)
+}
+
+// actionSetWorkspaceLabel will show the workspaces on the border of the
+// Channels pane, starting with the selected one. The workspaces with unread
+// messages are marked.
+func actionSetWorkspaceLabel(ctx *context.AppContext) {
+	if len(ctx.Workspaces) < 2 {
+		return
+	}
+
+	var names []string
+	for i := range ctx.Workspaces {
+		ws := ctx.Workspaces[(ctx.SelectedWorkspace+i)%len(ctx.Workspaces)]
+
+		name := ws.Name
+		if ws.Unread {
+			name = components.IconNotification + name
+		}
+		names = append(names, name)
+	}
+
+	ctx.View.Channels.SetBorderLabel(strings.Join(names, " | "))
+	render(ctx.View.Channels)
+}
+
+// getWorkspaceName returns the name of the workspace of the service of the
+// context, it is empty when there is only one workspace
+func getWorkspaceName(ctx *context.AppContext) string {
+	if len(ctx.Workspaces) < 2 {
+		return ""
+	}
+
+	for _, ws := range ctx.Workspaces {
+		if ws.Service == ctx.Service {
+			return ws.Name
+		}
+	}
+	return ""
+}
+
+// actionBackgroundEvent will handle an event of a workspace that isn't
+// selected. Nothing is rendered, new messages only mark the channel and the
+// workspace as unread.
+func actionBackgroundEvent(ctx *context.AppContext, ws *context.Workspace, rtmEvent slack.RTMEvent) {
+	switch ev := rtmEvent.Data.(type) {
+	case *slack.MessageEvent:
+		// Changes to existing messages aren't new messages
+		switch ev.SubType {
+		case "message_changed", "message_deleted", "message_replied":
+			return
+		}
+
+		ws.View.Channels.SetLatest(ev.Channel, time.Now())
+
+		if ev.User == ws.Service.CurrentUserID {
+			return
+		}
+
+		actionNewMessage(ctx, ws, ev)
+	case *slack.PresenceChangeEvent:
+		actionSetPresence(ctx, ws, ev.User, ev.Presence)
+	case *slack.ConnectingEvent:
+		actionSetConnection(ctx, ws, components.ConnectionConnecting)
+	case *slack.ConnectedEvent:
+		actionSetConnection(ctx, ws, components.ConnectionConnected)
+	case *slack.DisconnectedEvent:
+		if !ev.Intentional {
+			actionSetConnection(ctx, ws, components.ConnectionOffline)
+		}
+	case *slack.ConnectionErrorEvent:
+		actionSetConnection(ctx, ws, components.ConnectionOffline)
+	}
+}
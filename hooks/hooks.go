@@ -0,0 +1,163 @@
+// Package hooks runs the commands that are set in the hooks of the config on
+// the events of slack-term, the event is written as json to their stdin.
+// The filter hooks are run on the text of an outgoing message instead,
+// their output replaces it before it is sent.
+//
+//	h := hooks.New(cfg.Hooks, onError)
+//	h.Run(hooks.Event{Event: config.HookMention, Channel: "general"})
+//
+//	text, err := h.Filter(hooks.Event{Channel: "general", Text: "hi"})
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/erroneousboat/slack-term/config"
+)
+
+// timeout is the time a command may run before it is killed
+var timeout = 10 * time.Second
+
+// Event is the definition of an event on which the hooks are run, the
+// Workspace is set when there are multiple
+type Event struct {
+	Event     string `json:"event"`
+	Workspace string `json:"workspace,omitempty"`
+	ChannelID string `json:"channel_id"`
+	Channel   string `json:"channel"`
+	Type      string `json:"type"`
+	UserID    string `json:"user_id,omitempty"`
+	User      string `json:"user,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Thread    string `json:"thread,omitempty"`
+}
+
+// Hooks is the definition of the commands that are run, by the name of
+// their event
+type Hooks struct {
+	Commands map[string][]string
+
+	// OnError is called with the errors of the commands that are run in
+	// the background
+	OnError func(err error)
+}
+
+// New is the constructor for the Hooks
+func New(commands map[string][]string, onError func(err error)) *Hooks {
+	return &Hooks{
+		Commands: commands,
+		OnError:  onError,
+	}
+}
+
+// Has returns whether there are commands for the event
+func (h *Hooks) Has(event string) bool {
+	return len(h.Commands[event]) > 0
+}
+
+// Run will run the commands of the event in the background, one after
+// another
+func (h *Hooks) Run(ev Event) {
+	commands := h.Commands[ev.Event]
+	if len(commands) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		h.error(ev.Event, err)
+		return
+	}
+
+	go func() {
+		for _, command := range commands {
+			if _, err := Exec(command, data, ev.environ()); err != nil {
+				h.error(ev.Event, err)
+			}
+		}
+	}()
+}
+
+// Filter will run the filter commands one after another, every command
+// gets the text on its stdin and its stdout replaces the text. The event
+// is set in the environment of the commands.
+func (h *Hooks) Filter(ev Event) (string, error) {
+	text := ev.Text
+	for _, command := range h.Commands[config.HookMessageFilter] {
+		out, err := Exec(command, []byte(text), ev.environ())
+		if err != nil {
+			return "", err
+		}
+		text = strings.TrimRight(string(out), "\n")
+	}
+
+	return text, nil
+}
+
+// error will pass the error of a hook to OnError
+func (h *Hooks) error(event string, err error) {
+	if h.OnError != nil {
+		h.OnError(fmt.Errorf("%s hook: %s", event, err.Error()))
+	}
+}
+
+// environ returns the environment variables of the event
+func (ev Event) environ() []string {
+	return []string{
+		"SLACK_TERM_EVENT=" + ev.Event,
+		"SLACK_TERM_WORKSPACE=" + ev.Workspace,
+		"SLACK_TERM_CHANNEL_ID=" + ev.ChannelID,
+		"SLACK_TERM_CHANNEL=" + ev.Channel,
+		"SLACK_TERM_THREAD=" + ev.Thread,
+	}
+}
+
+// Exec will run the command with the shell, with the stdin and the
+// environment variables added to the ones of slack-term, and returns its
+// stdout
+func Exec(command string, stdin []byte, env []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), env...)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// The commands that are started by the command keep its stdout open
+	// after it was killed, Wait would only return once they're done
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", err.Error(), msg)
+		}
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erroneousboat/slack-term/config"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		want     string
+		err      string
+	}{
+		{"no filters", nil, "hello world", ""},
+		{"upper-case", []string{"tr a-z A-Z"}, "HELLO WORLD", ""},
+		{"chained", []string{"tr a-z A-Z", "sed \"s/WORLD/#$SLACK_TERM_CHANNEL/\""}, "HELLO #general", ""},
+		{"empty output", []string{"cat >/dev/null"}, "", ""},
+		{"non-zero exit", []string{"exit 3"}, "", "exit status 3"},
+		{"stderr", []string{"echo 'no spelling' >&2; exit 1"}, "", "exit status 1: no spelling"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := New(map[string][]string{config.HookMessageFilter: test.commands}, nil)
+
+			got, err := h.Filter(Event{
+				Event:   config.HookMessageFilter,
+				Channel: "general",
+				Text:    "hello world",
+			})
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected the error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	previous := timeout
+	timeout = 100 * time.Millisecond
+	defer func() { timeout = previous }()
+
+	// The command that is started by the shell keeps its stdout open
+	start := time.Now()
+	_, err := Exec("sleep 5; echo done", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the command was waited for %s after its timeout", elapsed)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slack-term-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "event")
+	errors := make(chan error, 1)
+
+	h := New(map[string][]string{
+		config.HookMention: {"cat > " + path, "exit 2"},
+	}, func(err error) {
+		errors <- err
+	})
+
+	ev := Event{
+		Event:     config.HookMention,
+		ChannelID: "C00000001",
+		Channel:   "general",
+		Type:      "channel",
+		Text:      "hello @slack-term",
+	}
+	h.Run(ev)
+
+	// The commands are run one after another, the error of the second
+	// one is passed to OnError once the first one wrote the event
+	select {
+	case err := <-errors:
+		if want := "mention hook: exit status 2"; err.Error() != want {
+			t.Errorf("expected the error %q, got %q", want, err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error of the hook wasn't passed to OnError")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Event
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != ev {
+		t.Errorf("expected the event %+v on stdin, got %+v", ev, got)
+	}
+
+	// Without commands for the event nothing is run
+	h.Run(Event{Event: config.HookMessageReceived})
+	select {
+	case err := <-errors:
+		t.Errorf("a hook was run without commands: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
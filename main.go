@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/OpenPeeDeeP/xdg"
 	"github.com/erroneousboat/termui"
@@ -31,16 +32,18 @@ WEBSITE:
 GLOBAL OPTIONS:
    -config [path-to-config-file]
    -token [slack-token]
+   -backend [slack|fake]
    -debug
    -help, -h
 `
 )
 
 var (
-	flgConfig string
-	flgToken  string
-	flgDebug  bool
-	flgUsage  bool
+	flgConfig  string
+	flgToken   string
+	flgBackend string
+	flgDebug   bool
+	flgUsage   bool
 )
 
 func init() {
@@ -63,6 +66,13 @@ func init() {
 		"the slack token",
 	)
 
+	flag.StringVar(
+		&flgBackend,
+		"backend",
+		"slack",
+		"the backend to use, 'fake' runs an offline workspace",
+	)
+
 	flag.BoolVar(
 		&flgDebug,
 		"debug",
@@ -94,10 +104,14 @@ func main() {
 	}
 	termui.DefaultEvtStream = customEvtStream
 
+	// The changes to the favourites and the collapsed sections are saved
+	// apart from the config file
+	sidebarFile := filepath.Join(xdg.New("slack-term", "").DataHome(), "sidebar")
+
 	// Create context
 	usage := fmt.Sprintf(USAGE, VERSION)
 	ctx, err := context.CreateAppContext(
-		flgConfig, flgToken, flgDebug, VERSION, usage,
+		flgConfig, flgToken, flgBackend, flgDebug, VERSION, usage, sidebarFile,
 	)
 	if err != nil {
 		termbox.Close()
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/0xAX/notificator"
+
+	"github.com/erroneousboat/slack-term/hooks"
+)
+
+// Desktop is the Notifier that shows desktop notifications
+type Desktop struct {
+	notificator *notificator.Notificator
+}
+
+// NewDesktop is the constructor for the Desktop notifier, it fails when
+// desktop notifications aren't supported
+func NewDesktop() (*Desktop, error) {
+	n := notificator.New(notificator.Options{AppName: "slack-term"})
+	if n == nil {
+		return nil, errors.New(
+			"desktop notifications are not supported for your OS",
+		)
+	}
+
+	return &Desktop{notificator: n}, nil
+}
+
+// Notify implements Notifier
+func (d *Desktop) Notify(n Notification) error {
+	urgency := notificator.UR_NORMAL
+	if n.Mention {
+		urgency = notificator.UR_CRITICAL
+	}
+
+	return d.notificator.Push(n.Title(), n.Body(), "", urgency)
+}
+
+// Bell is the Notifier that only rings the terminal bell
+type Bell struct {
+	Writer io.Writer
+}
+
+// Notify implements Notifier
+func (b *Bell) Notify(n Notification) error {
+	_, err := fmt.Fprint(b.Writer, "\a")
+	return err
+}
+
+// OSC is the Notifier that sends the notification to the terminal with an
+// escape sequence, the terminal shows it even when slack-term runs on
+// another machine. Code 9 is supported by iTerm2 and Windows Terminal, and
+// 777 by rxvt and kitty. Within tmux the sequence is passed through to
+// the terminal, which needs the allow-passthrough option of tmux.
+type OSC struct {
+	Writer io.Writer
+	Code   int
+	Tmux   bool
+}
+
+// Notify implements Notifier
+func (o *OSC) Notify(n Notification) error {
+	var seq string
+	switch o.Code {
+	case 777:
+		seq = fmt.Sprintf(
+			"\x1b]777;notify;%s;%s\x07",
+			strings.Replace(n.Title(), ";", ",", -1), n.Body(),
+		)
+	default:
+		seq = fmt.Sprintf("\x1b]9;%s: %s\x07", n.Title(), n.Body())
+	}
+
+	if o.Tmux {
+		seq = "\x1bPtmux;" + strings.Replace(seq, "\x1b", "\x1b\x1b", -1) + "\x1b\\"
+	}
+
+	_, err := fmt.Fprint(o.Writer, seq)
+	return err
+}
+
+// Command is the Notifier that runs a command with the shell, the
+// notification is written as json to its stdin
+type Command struct {
+	Command string
+}
+
+// Notify implements Notifier
+func (c *Command) Notify(n Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	_, err = hooks.Exec(c.Command, data, nil)
+	return err
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOSC(t *testing.T) {
+	n := Notification{
+		Workspace: "a;b",
+		Channel:   "general",
+		Type:      "channel",
+		User:      "alice",
+		Text:      "hi;\x1b]9;spoofed\x07",
+		Count:     1,
+	}
+
+	tests := []struct {
+		code int
+		tmux bool
+		want string
+	}{
+		{9, false, "\x1b]9;a;b/#general: alice: hi;]9;spoofed\x07"},
+		{777, false, "\x1b]777;notify;a,b/#general;alice: hi;]9;spoofed\x07"},
+		{9, true, "\x1bPtmux;\x1b\x1b]9;a;b/#general: alice: hi;]9;spoofed\x07\x1b\\"},
+		{777, true, "\x1bPtmux;\x1b\x1b]777;notify;a,b/#general;alice: hi;]9;spoofed\x07\x1b\\"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		o := &OSC{Writer: &buf, Code: test.code, Tmux: test.tmux}
+
+		if err := o.Notify(n); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("OSC %d (tmux %v): expected %q, got %q", test.code, test.tmux, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// Coalescer is a Notifier that combines the notifications of a channel,
+// the notifications that arrive within the Delay of the first one are
+// delivered as one. Every channel is coalesced on its own.
+type Coalescer struct {
+	Notifier Notifier
+	Delay    time.Duration
+
+	// OnError is called with the errors of the notifications that are
+	// delivered after the Delay
+	OnError func(err error)
+
+	mutex   sync.Mutex
+	pending map[string]*Notification
+}
+
+// NewCoalescer is the constructor for the Coalescer
+func NewCoalescer(notifier Notifier, delay time.Duration, onError func(err error)) *Coalescer {
+	return &Coalescer{
+		Notifier: notifier,
+		Delay:    delay,
+		OnError:  onError,
+		pending:  make(map[string]*Notification),
+	}
+}
+
+// Notify implements Notifier, the notification is delivered after the
+// Delay, together with the ones of the same channel that follow it
+func (c *Coalescer) Notify(n Notification) error {
+	if n.Count == 0 {
+		n.Count = 1
+	}
+
+	key := n.Workspace + "/" + n.ChannelID
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if pending, ok := c.pending[key]; ok {
+		pending.User = n.User
+		pending.Text = n.Text
+		pending.Mention = pending.Mention || n.Mention
+		pending.Count += n.Count
+		return nil
+	}
+
+	c.pending[key] = &n
+
+	time.AfterFunc(c.Delay, func() {
+		c.mutex.Lock()
+		pending := c.pending[key]
+		delete(c.pending, key)
+		c.mutex.Unlock()
+
+		if err := c.Notifier.Notify(*pending); err != nil && c.OnError != nil {
+			c.OnError(err)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+// recorder is the Notifier that passes the notifications that are
+// delivered to a channel
+type recorder chan Notification
+
+// Notify implements Notifier
+func (r recorder) Notify(n Notification) error {
+	r <- n
+	return nil
+}
+
+func TestCoalescer(t *testing.T) {
+	notifications := make(recorder, 10)
+	c := NewCoalescer(notifications, 100*time.Millisecond, nil)
+
+	for _, n := range []Notification{
+		{ChannelID: "C1", User: "alice", Text: "first", Mention: true},
+		{ChannelID: "C2", User: "carol", Text: "elsewhere"},
+		{ChannelID: "C1", User: "bob", Text: "second", Count: 2},
+		{Workspace: "work", ChannelID: "C1", User: "dave", Text: "other workspace"},
+	} {
+		if err := c.Notify(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]Notification{
+		"/C1":     {ChannelID: "C1", User: "bob", Text: "second", Mention: true, Count: 3},
+		"/C2":     {ChannelID: "C2", User: "carol", Text: "elsewhere", Count: 1},
+		"work/C1": {Workspace: "work", ChannelID: "C1", User: "dave", Text: "other workspace", Count: 1},
+	}
+
+	for i, count := 0, len(want); i < count; i++ {
+		select {
+		case n := <-notifications:
+			key := n.Workspace + "/" + n.ChannelID
+			if n != want[key] {
+				t.Errorf("expected %+v, got %+v", want[key], n)
+			}
+			delete(want, key)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the notifications weren't delivered")
+		}
+	}
+
+	select {
+	case n := <-notifications:
+		t.Errorf("the notifications weren't coalesced, got %+v", n)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// After the Delay a new notification is delivered on its own
+	c.Notify(Notification{ChannelID: "C1", Text: "later"})
+	select {
+	case n := <-notifications:
+		if n.Count != 1 || n.Text != "later" {
+			t.Errorf("expected a new notification, got %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the notification wasn't delivered")
+	}
+}
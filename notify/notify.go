@@ -0,0 +1,109 @@
+// Package notify delivers the notifications of new messages to the user.
+// A Notifier is a backend, like the desktop notifications or the escape
+// sequences of the terminal, and the Coalescer combines the notifications
+// of a channel that arrive in short succession.
+//
+//	notifier, err := notify.New(cfg)
+//	coalescer := notify.NewCoalescer(notifier, 2*time.Second, onError)
+//
+//	coalescer.Notify(notify.Notification{Channel: "general", Count: 1})
+package notify
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/erroneousboat/slack-term/config"
+)
+
+// previewLength is the maximum amount of characters of the preview of the
+// message
+const previewLength = 100
+
+// Notifier is the definition of a backend that delivers notifications
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Notification is the notification of one or more new messages in a
+// channel, the User and the Text are the ones of the latest message
+type Notification struct {
+	Workspace string `json:"workspace,omitempty"`
+	ChannelID string `json:"channel_id"`
+	Channel   string `json:"channel"`
+	Type      string `json:"type"`
+	User      string `json:"user"`
+	Text      string `json:"text"`
+	Mention   bool   `json:"mention"`
+	Count     int    `json:"count"`
+}
+
+// Title returns the title of the notification, the channel and, when
+// there are multiple, the workspace
+func (n Notification) Title() string {
+	channel := n.Channel
+	switch n.Type {
+	case "channel":
+		channel = "#" + channel
+	case "im":
+		channel = "@" + channel
+	}
+
+	if n.Workspace != "" {
+		return fmt.Sprintf("%s/%s", n.Workspace, channel)
+	}
+	return channel
+}
+
+// Body returns the preview of the latest message, preceded by the amount
+// of messages when there are multiple
+func (n Notification) Body() string {
+	body := n.Preview()
+	if n.User != "" {
+		body = fmt.Sprintf("%s: %s", n.User, body)
+	}
+
+	if n.Count > 1 {
+		body = fmt.Sprintf("%d new messages, %s", n.Count, body)
+	}
+
+	return body
+}
+
+// Preview returns the text of the message on a single line, without
+// control characters, and shortened when it is too long
+func (n Notification) Preview() string {
+	text := strings.Join(strings.Fields(n.Text), " ")
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+
+	runes := []rune(text)
+	if len(runes) > previewLength {
+		return string(runes[:previewLength-1]) + "…"
+	}
+	return text
+}
+
+// New will create the Notifier that is set in the config
+func New(cfg *config.Config) (Notifier, error) {
+	switch cfg.Notifier {
+	case config.NotifierDesktop, "":
+		return NewDesktop()
+	case config.NotifierBell:
+		return &Bell{Writer: os.Stdout}, nil
+	case config.NotifierOSC9:
+		return &OSC{Writer: os.Stdout, Code: 9, Tmux: os.Getenv("TMUX") != ""}, nil
+	case config.NotifierOSC777:
+		return &OSC{Writer: os.Stdout, Code: 777, Tmux: os.Getenv("TMUX") != ""}, nil
+	case config.NotifierCommand:
+		return &Command{Command: cfg.NotifyCommand}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notifier: %s", cfg.Notifier)
+	}
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	long := strings.Repeat("a", previewLength+10)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"hello world", "hello world"},
+		{"  hello\n\tworld  ", "hello world"},
+		{"bell\a and\x1b[31m escape", "bell and[31m escape"},
+		{"\x1b]9;spoofed\x07", "]9;spoofed"},
+		{long, strings.Repeat("a", previewLength-1) + "…"},
+		{strings.Repeat("é", previewLength), strings.Repeat("é", previewLength)},
+	}
+
+	for _, test := range tests {
+		if got := (Notification{Text: test.text}).Preview(); got != test.want {
+			t.Errorf("Preview(%q): expected %q, got %q", test.text, test.want, got)
+		}
+	}
+}
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		n    Notification
+		want string
+	}{
+		{Notification{Text: "hello", Count: 1}, "hello"},
+		{Notification{User: "alice", Text: "hello", Count: 1}, "alice: hello"},
+		{Notification{User: "alice", Text: "hello\nthere", Count: 3}, "3 new messages, alice: hello there"},
+	}
+
+	for _, test := range tests {
+		if got := test.n.Body(); got != test.want {
+			t.Errorf("Body(%+v): expected %q, got %q", test.n, test.want, got)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		n    Notification
+		want string
+	}{
+		{Notification{Channel: "general", Type: "channel"}, "#general"},
+		{Notification{Channel: "alice", Type: "im"}, "@alice"},
+		{Notification{Channel: "alice, bob", Type: "mpim"}, "alice, bob"},
+		{Notification{Workspace: "work", Channel: "general", Type: "channel"}, "work/#general"},
+	}
+
+	for _, test := range tests {
+		if got := test.n.Title(); got != test.want {
+			t.Errorf("Title(%+v): expected %q, got %q", test.n, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	cfg "github.com/erroneousboat/slack-term/config"
+)
+
+const (
+	BackendSlack = "slack"
+	BackendFake  = "fake"
+)
+
+// apiTimeout is the time after which a request to the slack api is given up
+const apiTimeout = 30 * time.Second
+
+// apiClient is the http client of the requests to the slack api, of the
+// slack.Client and of the ones that it doesn't make itself
+var apiClient = &http.Client{Timeout: apiTimeout}
+
+// apiMethodURL returns the url of the method of the slack api, the trailing
+// slash of the apiURL is optional and the default slack api url is used when
+// it is empty
+func apiMethodURL(apiURL string, method string) string {
+	if apiURL == "" {
+		apiURL = slack.APIURL
+	}
+	return strings.TrimSuffix(apiURL, "/") + "/" + method
+}
+
+// Backend is the definition of the calls the SlackService makes to a slack
+// workspace. The method signatures follow the ones of slack.Client, so that
+// the SlackBackend can simply embed it.
+type Backend interface {
+	AuthTest() (*slack.AuthTestResponse, error)
+	GetUsers() ([]slack.User, error)
+	GetUserInfo(user string) (*slack.User, error)
+	GetBotInfo(bot string) (*slack.Bot, error)
+	GetUserPresence(user string) (*slack.UserPresence, error)
+	GetUserGroups(options ...slack.GetUserGroupsOption) ([]slack.UserGroup, error)
+	SetUserPresence(presence string) error
+	GetConversations(params *slack.GetConversationsParameters) ([]slack.Channel, string, error)
+	GetConversationInfo(channelID string, includeLocale bool) (*slack.Channel, error)
+	GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
+	GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
+	OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error)
+	JoinConversation(channelID string) (*slack.Channel, string, []string, error)
+	LeaveConversation(channelID string) (bool, error)
+	CreateConversation(channelName string, isPrivate bool) (*slack.Channel, error)
+	ArchiveConversation(channelID string) error
+	SearchMessages(query string, params slack.SearchParameters) (*slack.SearchMessages, error)
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	DeleteMessage(channel, messageTimestamp string) (string, string, error)
+	AddReaction(name string, item slack.ItemRef) error
+	RemoveReaction(name string, item slack.ItemRef) error
+	SetChannelReadMark(channelID, ts string) error
+	SetGroupReadMark(group, ts string) error
+	MarkIMChannel(channel, ts string) error
+
+	// GetNotificationPrefs will get the muted channels and the
+	// notification levels of the channels from the preferences of the
+	// user, slack.Client doesn't decode the latter
+	GetNotificationPrefs() (*NotificationPrefs, error)
+
+	// Connect will start the real-time connection with the workspace and
+	// returns the channel on which the incoming events are delivered
+	Connect() chan slack.RTMEvent
+}
+
+// NotificationPrefs are the notification preferences of the user, Levels
+// contains the desktop notification level of the channels that have one:
+// everything, mentions or nothing
+type NotificationPrefs struct {
+	MutedChannels []string
+	Levels        map[string]string
+}
+
+// NewBackend will create the Backend of the workspace by its name, as it is
+// set with the -backend command-line flag
+func NewBackend(name string, workspace cfg.Workspace) (Backend, error) {
+	switch name {
+	case BackendSlack, "":
+		backend := NewSlackBackend(workspace.SlackToken, workspace.APIURL)
+
+		if workspace.EventSource == cfg.EventSourceSocketMode {
+			if workspace.AppToken == "" {
+				return nil, errors.New("please specify the 'app_token' to use socket mode")
+			}
+			backend.AppToken = workspace.AppToken
+		}
+
+		return backend, nil
+	case BackendFake:
+		backend := NewFakeBackend()
+		if workspace.Name != "" {
+			backend.Team = workspace.Name
+		}
+
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unsupported backend: %s", name)
+	}
+}
+
+// SlackBackend is the Backend that talks to the slack api, the calls are
+// handled by the embedded slack.Client and the incoming events by the RTM,
+// or by SocketMode when the AppToken is set
+type SlackBackend struct {
+	*slack.Client
+	RTM        *slack.RTM
+	SocketMode *SocketMode
+	APIURL     string
+	Token      string
+	AppToken   string
+}
+
+// NewSlackBackend is the constructor for the SlackBackend, when apiURL is
+// empty the default slack api url is used
+func NewSlackBackend(token string, apiURL string) *SlackBackend {
+	// The slack.Client appends the method to the url, it has to end with
+	// a slash
+	apiURL = apiMethodURL(apiURL, "")
+
+	return &SlackBackend{
+		Client: slack.New(
+			token,
+			slack.OptionAPIURL(apiURL),
+			slack.OptionHTTPClient(apiClient),
+		),
+		APIURL: apiURL,
+		Token:  token,
+	}
+}
+
+// Connect will create the RTM, or SocketMode when the AppToken is set, and
+// let it manage the websocket connection
+func (b *SlackBackend) Connect() chan slack.RTMEvent {
+	if b.AppToken != "" {
+		b.SocketMode = NewSocketMode(b.AppToken, b.APIURL)
+		go b.SocketMode.ManageConnection()
+
+		return b.SocketMode.IncomingEvents
+	}
+
+	b.RTM = b.Client.NewRTM()
+	go b.RTM.ManageConnection()
+
+	return b.RTM.IncomingEvents
+}
+
+// GetNotificationPrefs implements Backend, the notification levels are in
+// all_notifications_prefs, which is a json encoded string
+//
+// https://api.slack.com/methods/users.prefs.get
+func (b *SlackBackend) GetNotificationPrefs() (*NotificationPrefs, error) {
+	req, err := http.NewRequest(
+		http.MethodPost, apiMethodURL(b.APIURL, "users.prefs.get"), nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, &slack.RateLimitedError{
+			RetryAfter: time.Duration(retryAfter) * time.Second,
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("users.prefs.get: %s", resp.Status)
+	}
+
+	var response struct {
+		slack.SlackResponse
+		Prefs struct {
+			MutedChannels         string `json:"muted_channels"`
+			AllNotificationsPrefs string `json:"all_notifications_prefs"`
+		} `json:"prefs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	if !response.Ok {
+		return nil, errors.New(response.Error)
+	}
+
+	prefs := &NotificationPrefs{Levels: make(map[string]string)}
+	for _, channelID := range strings.Split(response.Prefs.MutedChannels, ",") {
+		if channelID != "" {
+			prefs.MutedChannels = append(prefs.MutedChannels, channelID)
+		}
+	}
+
+	if response.Prefs.AllNotificationsPrefs == "" {
+		return prefs, nil
+	}
+
+	var notifications struct {
+		Channels map[string]struct {
+			Desktop string `json:"desktop"`
+		} `json:"channels"`
+	}
+	err = json.Unmarshal([]byte(response.Prefs.AllNotificationsPrefs), &notifications)
+	if err != nil {
+		return nil, err
+	}
+
+	for channelID, channel := range notifications.Channels {
+		if channel.Desktop != "" && channel.Desktop != "default" {
+			prefs.Levels[channelID] = channel.Desktop
+		}
+	}
+
+	return prefs, nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestAPIMethodURL(t *testing.T) {
+	tests := []struct {
+		apiURL string
+		want   string
+	}{
+		{"", slack.APIURL + "users.prefs.get"},
+		{"http://127.0.0.1:8080/api/", "http://127.0.0.1:8080/api/users.prefs.get"},
+		{"http://127.0.0.1:8080/api", "http://127.0.0.1:8080/api/users.prefs.get"},
+	}
+
+	for _, test := range tests {
+		if got := apiMethodURL(test.apiURL, "users.prefs.get"); got != test.want {
+			t.Errorf("apiMethodURL(%q): expected %s, got %s", test.apiURL, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// Cache stores the users, the channels and the recent messages of the
+// channels of a workspace on disk. They are shown at startup and when the
+// channel is changed, before slack has responded, and are replaced with
+// what slack responds.
+//
+// The cached messages can be searched, they're added to an Index when the
+// first search is done.
+//
+// The files are json, the messages of every channel are in their own file:
+//
+//	<dir>/users.json
+//	<dir>/channels.json
+//	<dir>/messages/<channel id>.json
+type Cache struct {
+	Dir string
+
+	mutex sync.Mutex
+	index *Index
+
+	// messagesMutex serializes the merging of the messages of the
+	// channels, so that concurrent merges don't undo each other
+	messagesMutex sync.Mutex
+}
+
+// maxCachedMessages is the amount of messages of a channel that are cached,
+// the oldest ones are removed first
+const maxCachedMessages = 1000
+
+// NewCache is the constructor for the Cache, the files are stored in dir
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// GetUsers returns the users, and whether they were cached
+func (c *Cache) GetUsers() ([]User, bool) {
+	var users []User
+	ok := c.read("users.json", &users)
+	return users, ok
+}
+
+// SetUsers will store the users
+func (c *Cache) SetUsers(users []User) error {
+	return c.write("users.json", users)
+}
+
+// GetChannels returns the channels, and whether they were cached
+func (c *Cache) GetChannels() ([]slack.Channel, bool) {
+	var channels []slack.Channel
+	ok := c.read("channels.json", &channels)
+	return channels, ok
+}
+
+// SetChannels will store the channels
+func (c *Cache) SetChannels(channels []slack.Channel) error {
+	return c.write("channels.json", channels)
+}
+
+// GetMessages returns the cached messages of the channel, the newest first
+// as slack returns them, and whether they were cached
+func (c *Cache) GetMessages(channelID string) ([]slack.Message, bool) {
+	var messages []slack.Message
+	ok := c.read(messagesFile(channelID), &messages)
+	return messages, ok
+}
+
+// SetMessages will store the most recent messages of the channel, the
+// newest first. They replace the cached messages from the oldest of them
+// onwards, so that the messages that were deleted are removed as well.
+func (c *Cache) SetMessages(channelID string, messages []slack.Message) error {
+	var oldest string
+	if len(messages) > 0 {
+		oldest = messages[len(messages)-1].Timestamp
+	}
+
+	return c.addMessages(channelID, messages, oldest)
+}
+
+// AddMessages will add messages of the channel, like the older ones that
+// were fetched when scrolling up, to the cached messages
+func (c *Cache) AddMessages(channelID string, messages []slack.Message) error {
+	return c.addMessages(channelID, messages, "")
+}
+
+// addMessages will merge the messages with the cached messages of the
+// channel, the cached messages from replaceFrom onwards are removed first
+func (c *Cache) addMessages(channelID string, messages []slack.Message, replaceFrom string) error {
+	c.messagesMutex.Lock()
+	defer c.messagesMutex.Unlock()
+
+	cached, _ := c.GetMessages(channelID)
+
+	merged := make(map[string]slack.Message)
+	for _, msg := range cached {
+		if replaceFrom == "" || msg.Timestamp < replaceFrom {
+			merged[msg.Timestamp] = msg
+		}
+	}
+	for _, msg := range messages {
+		merged[msg.Timestamp] = msg
+	}
+
+	messages = make([]slack.Message, 0, len(merged))
+	for _, msg := range merged {
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Timestamp > messages[j].Timestamp
+	})
+
+	if len(messages) > maxCachedMessages {
+		messages = messages[:maxCachedMessages]
+	}
+
+	c.mutex.Lock()
+	if c.index != nil {
+		c.index.SetMessages(channelID, messages)
+	}
+	c.mutex.Unlock()
+
+	return c.write(messagesFile(channelID), messages)
+}
+
+// Search will search the cached messages of all channels, see Index.Search
+func (c *Cache) Search(query string, count int) []IndexMatch {
+	return c.getIndex().Search(query, count)
+}
+
+// getIndex returns the Index of the cached messages, it is created when
+// it's used for the first time
+func (c *Cache) getIndex() *Index {
+	c.mutex.Lock()
+	index := c.index
+	c.mutex.Unlock()
+
+	if index != nil {
+		return index
+	}
+
+	index = NewIndex()
+
+	files, _ := ioutil.ReadDir(filepath.Join(c.Dir, "messages"))
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		channelID := strings.TrimSuffix(file.Name(), ".json")
+		if messages, ok := c.GetMessages(channelID); ok {
+			index.SetMessages(channelID, messages)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another search could've created it in the meantime
+	if c.index == nil {
+		c.index = index
+	}
+
+	return c.index
+}
+
+// messagesFile returns the name of the file of the messages of a channel
+func messagesFile(channelID string) string {
+	return filepath.Join("messages", channelID+".json")
+}
+
+// read will decode the file into v, a file that can't be read or decoded
+// is treated as not being cached
+func (c *Cache) read(name string, v interface{}) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(c.Dir, name))
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+// write will encode v into the file. It's written to a temporary file
+// first, so that a file is never left half written.
+func (c *Cache) write(name string, v interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(c.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"net"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// IsRetryable returns whether a failed request to slack can be retried.
+// This is the case for rate limits, server errors and network errors.
+func IsRetryable(err error) bool {
+	switch e := err.(type) {
+	case interface{ Retryable() bool }:
+		return e.Retryable()
+	case net.Error:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryAfter returns the duration slack asks us to wait before retrying,
+// when the request was rate limited. Otherwise it returns 0.
+func RetryAfter(err error) time.Duration {
+	if e, ok := err.(*slack.RateLimitedError); ok {
+		return e.RetryAfter
+	}
+
+	return 0
+}
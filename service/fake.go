@@ -0,0 +1,1324 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// FakeBackend is an in-memory slack workspace which allows slack-term to be
+// run without a network connection (-backend fake). All the calls of the
+// SlackService are answered from the Users, Channels and Messages of the
+// workspace, and the events in Script are delivered as if they were
+// received over the RTM connection.
+type FakeBackend struct {
+	sync.Mutex
+
+	UserID   string
+	Team     string
+	Users    []slack.User
+	Channels []slack.Channel
+	Presence map[string]string
+
+	// UserGroups are the user groups of the workspace, with their users
+	UserGroups []slack.UserGroup
+
+	// Prefs are the notification preferences of the user
+	Prefs NotificationPrefs
+
+	// Messages contains the messages, including thread replies, of every
+	// channel ordered from oldest to newest
+	Messages map[string][]slack.Message
+
+	// Script contains the events that will be delivered after a
+	// connection has been made
+	Script []FakeEvent
+
+	// Failures contains the errors that will be returned by the next
+	// calls of a method, by its name, see FailNext
+	Failures map[string][]error
+
+	events      chan slack.RTMEvent
+	connections int
+	offline     bool
+	clock       time.Time
+}
+
+// FakeEvent is an event that will be delivered by the FakeBackend when
+// the Delay, starting from Connect, has passed
+type FakeEvent struct {
+	Delay time.Duration
+	Event slack.RTMEvent
+}
+
+// NewFakeBackend is the constructor for the FakeBackend, it will return a
+// small workspace with some users, channels, a thread and a script of
+// incoming events.
+func NewFakeBackend() *FakeBackend {
+	f := &FakeBackend{
+		UserID:   "U00000001",
+		Team:     "slack-term",
+		Presence: make(map[string]string),
+		Messages: make(map[string][]slack.Message),
+		Failures: make(map[string][]error),
+		// The seeded messages are given fixed timestamps, so that the
+		// workspace is rendered the same way every time
+		clock: time.Date(2020, time.January, 1, 9, 0, 0, 0, time.Local),
+	}
+
+	f.AddUser("U00000001", "slack-term", "Slack Term")
+	f.AddUser("U00000002", "alice", "Alice Liddell")
+	f.AddUser("U00000003", "bob", "Bob Dobbs")
+	f.AddUser("U00000004", "carol", "Carol Shaw")
+
+	f.Presence["U00000002"] = "active"
+
+	f.UserGroups = []slack.UserGroup{
+		{
+			ID:        "S00000001",
+			Name:      "Developers",
+			Handle:    "devs",
+			Users:     []string{"U00000001", "U00000003"},
+			UserCount: 2,
+		},
+	}
+
+	// Only the mentions of the busy random channel are notified
+	f.Prefs.Levels = map[string]string{"C00000002": "mentions"}
+
+	general := slack.Channel{IsChannel: true, IsMember: true, IsGeneral: true}
+	general.Topic.Value = "Company wide announcements and work-based matters"
+	general.NumMembers = 4
+	f.AddChannel(general, "C00000001", "general")
+	random := slack.Channel{IsChannel: true, IsMember: true}
+	random.NumMembers = 4
+	f.AddChannel(random, "C00000002", "random")
+	announcements := slack.Channel{IsChannel: true}
+	announcements.Topic.Value = "Releases and other news"
+	announcements.NumMembers = 2
+	f.AddChannel(announcements, "C00000003", "announcements")
+
+	group := slack.Channel{IsMember: true}
+	group.IsGroup = true
+	f.AddChannel(group, "G00000001", "secret")
+	group.IsMpIM = true
+	f.AddChannel(group, "G00000002", "mpdm-alice--bob--slack-term-1")
+
+	im := slack.Channel{}
+	im.IsIM = true
+	im.User = "U00000002"
+	f.AddChannel(im, "D00000001", "")
+	im.User = "U00000003"
+	f.AddChannel(im, "D00000002", "")
+
+	greeting := f.AddMessage("C00000001", "U00000002", "Good morning everyone!", "")
+	parent := f.AddMessage("C00000001", "U00000003", "Who is up for lunch?", "")
+	f.AddMessage("C00000001", "U00000004", "Count me in", parent.Timestamp)
+	f.AddMessage("C00000001", "U00000002", "Same here :+1:", parent.Timestamp)
+	f.AddMessage("C00000001", "U00000001", "I'll book a table <@U00000003>", "")
+
+	// A long history, that doesn't fit on a single page
+	users := []string{"U00000002", "U00000003", "U00000004"}
+	for i := 1; i <= 100; i++ {
+		f.AddMessage("C00000002", users[i%len(users)], fmt.Sprintf("Random thought #%d", i), "")
+	}
+
+	// A long thread, that doesn't fit on a single page
+	poll := f.AddMessage("C00000002", "U00000002", "Tabs or spaces? Reply in the thread", "")
+	for i := 1; i <= 40; i++ {
+		f.AddMessage("C00000002", users[i%len(users)], fmt.Sprintf("Vote #%d", i), poll.Timestamp)
+	}
+	f.AddMessage("C00000002", "U00000004", "Look at this cat", "")
+
+	f.AddReactions("C00000001", greeting.Timestamp, slack.ItemReaction{
+		Name:  "wave",
+		Count: 2,
+		Users: []string{"U00000003", "U00000004"},
+	})
+	f.AddMessage("G00000001", "U00000003", "Don't tell anyone", "")
+	f.AddMessage("D00000001", "U00000002", "Hey, do you have a minute?", "")
+
+	f.Script = []FakeEvent{
+		{
+			Delay: 5 * time.Second,
+			Event: f.messageEvent(
+				slack.Message{Msg: slack.Msg{
+					Channel: "C00000001",
+					User:    "U00000002",
+					Text:    "Anyone seen the build break?",
+				}},
+			),
+		},
+		{
+			Delay: 8 * time.Second,
+			Event: slack.RTMEvent{
+				Type: "presence_change",
+				Data: &slack.PresenceChangeEvent{
+					Type:     "presence_change",
+					User:     "U00000003",
+					Presence: "active",
+				},
+			},
+		},
+		{
+			Delay: 12 * time.Second,
+			Event: f.messageEvent(
+				slack.Message{Msg: slack.Msg{
+					Channel:         "C00000001",
+					User:            "U00000003",
+					Text:            "Great, see you at noon",
+					ThreadTimestamp: parent.Timestamp,
+				}},
+			),
+		},
+		{
+			Delay: 15 * time.Second,
+			Event: f.messageEvent(
+				slack.Message{Msg: slack.Msg{
+					Channel: "D00000001",
+					User:    "U00000002",
+					Text:    "Ping <@U00000001>",
+				}},
+			),
+		},
+	}
+
+	return f
+}
+
+// AddUser adds a user to the workspace
+func (f *FakeBackend) AddUser(id string, name string, realName string) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.Users = append(f.Users, slack.User{
+		ID:       id,
+		Name:     name,
+		RealName: realName,
+		Profile: slack.UserProfile{
+			RealName:    realName,
+			DisplayName: name,
+		},
+	})
+}
+
+// AddChannel adds a conversation to the workspace, the type of the
+// conversation is determined by the Is* fields of chn
+func (f *FakeBackend) AddChannel(chn slack.Channel, id string, name string) {
+	f.Lock()
+	defer f.Unlock()
+
+	chn.ID = id
+	chn.Name = name
+	chn.IsOpen = true
+
+	f.Channels = append(f.Channels, chn)
+}
+
+// AddMessage adds a message to a channel in the workspace, when threadID is
+// set the message will be a reply to that thread
+func (f *FakeBackend) AddMessage(channelID string, userID string, text string, threadID string) slack.Message {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.addMessage(slack.Message{Msg: slack.Msg{
+		Channel:         channelID,
+		User:            userID,
+		Text:            text,
+		ThreadTimestamp: threadID,
+	}})
+}
+
+// AddReactions adds reactions to a message in the workspace
+func (f *FakeBackend) AddReactions(channelID string, timestamp string, reactions ...slack.ItemReaction) {
+	f.Lock()
+	defer f.Unlock()
+
+	if msg := f.message(channelID, timestamp); msg != nil {
+		msg.Reactions = append(msg.Reactions, reactions...)
+	}
+}
+
+// FailNext will make the next calls of the method, by its name, return the
+// errors in order. E.g. to have the history of a channel be rate limited
+// once:
+//
+//	f.FailNext("GetConversationHistory", &slack.RateLimitedError{RetryAfter: time.Second})
+func (f *FakeBackend) FailNext(method string, errs ...error) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.Failures[method] = append(f.Failures[method], errs...)
+}
+
+// failure returns the next error of the method that was set by FailNext
+func (f *FakeBackend) failure(method string) error {
+	errs := f.Failures[method]
+	if len(errs) == 0 {
+		return nil
+	}
+
+	f.Failures[method] = errs[1:]
+	return errs[0]
+}
+
+// Emit will deliver an event on the connection, it is ignored when no
+// connection has been made or when we're disconnected. When nothing reads
+// the events, e.g. after the websocket of the slacktest Server was closed,
+// they're dropped once the connection is full instead of blocking.
+func (f *FakeBackend) Emit(ev slack.RTMEvent) {
+	f.Lock()
+	events := f.events
+	offline := f.offline
+	f.Unlock()
+
+	if events == nil || offline {
+		return
+	}
+
+	select {
+	case events <- ev:
+	default:
+	}
+}
+
+// AuthTest implements Backend
+func (f *FakeBackend) AuthTest() (*slack.AuthTestResponse, error) {
+	user, err := f.GetUserInfo(f.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &slack.AuthTestResponse{
+		Team:   f.Team,
+		User:   user.Name,
+		UserID: user.ID,
+	}, nil
+}
+
+// GetUsers implements Backend
+func (f *FakeBackend) GetUsers() ([]slack.User, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetUsers"); err != nil {
+		return nil, err
+	}
+
+	users := make([]slack.User, len(f.Users))
+	copy(users, f.Users)
+
+	return users, nil
+}
+
+// GetUserInfo implements Backend
+func (f *FakeBackend) GetUserInfo(userID string) (*slack.User, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	for _, user := range f.Users {
+		if user.ID == userID {
+			return &user, nil
+		}
+	}
+
+	return nil, errors.New("user_not_found")
+}
+
+// GetBotInfo implements Backend
+func (f *FakeBackend) GetBotInfo(botID string) (*slack.Bot, error) {
+	return nil, errors.New("bot_not_found")
+}
+
+// GetUserPresence implements Backend
+func (f *FakeBackend) GetUserPresence(userID string) (*slack.UserPresence, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetUserPresence"); err != nil {
+		return nil, err
+	}
+
+	presence, ok := f.Presence[userID]
+	if !ok {
+		presence = "away"
+	}
+
+	return &slack.UserPresence{
+		Presence: presence,
+		Online:   presence == "active",
+	}, nil
+}
+
+// GetUserGroups implements Backend, the users of the user groups are always
+// included
+func (f *FakeBackend) GetUserGroups(options ...slack.GetUserGroupsOption) ([]slack.UserGroup, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetUserGroups"); err != nil {
+		return nil, err
+	}
+
+	groups := make([]slack.UserGroup, len(f.UserGroups))
+	copy(groups, f.UserGroups)
+
+	return groups, nil
+}
+
+// SetUserPresence implements Backend
+func (f *FakeBackend) SetUserPresence(presence string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("SetUserPresence"); err != nil {
+		return err
+	}
+
+	if presence == "auto" {
+		presence = "active"
+	}
+	f.Presence[f.UserID] = presence
+
+	return nil
+}
+
+// GetConversations implements Backend, the Cursor is the index of the
+// next channel
+func (f *FakeBackend) GetConversations(params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetConversations"); err != nil {
+		return nil, "", err
+	}
+
+	types := make(map[string]bool)
+	for _, t := range params.Types {
+		types[t] = true
+	}
+
+	var chans []slack.Channel
+	for _, chn := range f.Channels {
+		var t string
+		switch {
+		case chn.IsIM:
+			t = "im"
+		case chn.IsMpIM:
+			t = "mpim"
+		case chn.IsGroup || chn.IsPrivate:
+			t = "private_channel"
+		default:
+			t = "public_channel"
+		}
+
+		if len(types) > 0 && !types[t] {
+			continue
+		}
+
+		if params.ExcludeArchived == "true" && chn.IsArchived {
+			continue
+		}
+
+		chans = append(chans, chn)
+	}
+
+	start, end, next := paginate(len(chans), params.Cursor, params.Limit)
+
+	return chans[start:end], next, nil
+}
+
+// GetConversationInfo implements Backend, the Latest message of the
+// channel is set, so that it can be compared with the LastRead
+func (f *FakeBackend) GetConversationInfo(channelID string, includeLocale bool) (*slack.Channel, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetConversationInfo"); err != nil {
+		return nil, err
+	}
+
+	chn := f.channel(channelID)
+	if chn == nil {
+		return nil, errors.New("channel_not_found")
+	}
+
+	info := *chn
+	msgs := f.Messages[channelID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		isReply := msgs[i].ThreadTimestamp != "" && msgs[i].ThreadTimestamp != msgs[i].Timestamp
+		if isReply && msgs[i].SubType != "thread_broadcast" {
+			continue
+		}
+
+		latest := msgs[i]
+		info.Latest = &latest
+		break
+	}
+
+	return &info, nil
+}
+
+// GetConversationHistory implements Backend, it returns the messages that
+// aren't thread replies, newest first. The Cursor is the index of the
+// next message.
+func (f *FakeBackend) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetConversationHistory"); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.Messages[params.ChannelID]; !ok && f.channel(params.ChannelID) == nil {
+		return nil, errors.New("channel_not_found")
+	}
+
+	var msgs []slack.Message
+	for i := len(f.Messages[params.ChannelID]) - 1; i >= 0; i-- {
+		msg := f.Messages[params.ChannelID][i]
+
+		isReply := msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp
+		if isReply && msg.SubType != "thread_broadcast" {
+			continue
+		}
+
+		if !inRange(msg.Timestamp, params.Oldest, params.Latest, params.Inclusive) {
+			continue
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	start, end, next := paginate(len(msgs), params.Cursor, params.Limit)
+
+	history := &slack.GetConversationHistoryResponse{
+		HasMore:  next != "",
+		Messages: msgs[start:end],
+	}
+	history.Ok = true
+	history.ResponseMetaData.NextCursor = next
+
+	return history, nil
+}
+
+// GetConversationReplies implements Backend, it returns the parent
+// message followed by the replies, oldest first. The Cursor is the index
+// of the next message.
+func (f *FakeBackend) GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetConversationReplies"); err != nil {
+		return nil, false, "", err
+	}
+
+	var msgs []slack.Message
+	for _, msg := range f.Messages[params.ChannelID] {
+		if msg.Timestamp != params.Timestamp && msg.ThreadTimestamp != params.Timestamp {
+			continue
+		}
+
+		if !inRange(msg.Timestamp, params.Oldest, params.Latest, params.Inclusive) {
+			continue
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	if len(msgs) == 0 {
+		return nil, false, "", errors.New("thread_not_found")
+	}
+
+	start, end, next := paginate(len(msgs), params.Cursor, params.Limit)
+
+	return msgs[start:end], next != "", next, nil
+}
+
+// OpenConversation implements Backend, it returns the direct message
+// conversation with a user, it is created when it doesn't exist yet
+func (f *FakeBackend) OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("OpenConversation"); err != nil {
+		return nil, false, false, err
+	}
+
+	if len(params.Users) != 1 {
+		return nil, false, false, errors.New("invalid_users")
+	}
+
+	var found bool
+	for _, user := range f.Users {
+		if user.ID == params.Users[0] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false, false, errors.New("user_not_found")
+	}
+
+	for i := range f.Channels {
+		chn := &f.Channels[i]
+		if chn.IsIM && chn.User == params.Users[0] {
+			alreadyOpen := chn.IsOpen
+			chn.IsOpen = true
+
+			info := *chn
+			return &info, alreadyOpen, alreadyOpen, nil
+		}
+	}
+
+	chn := slack.Channel{}
+	chn.ID = fmt.Sprintf("D%08d", len(f.Channels)+1)
+	chn.IsIM = true
+	chn.IsOpen = true
+	chn.User = params.Users[0]
+	f.Channels = append(f.Channels, chn)
+
+	return &chn, false, false, nil
+}
+
+// JoinConversation implements Backend, only public channels can be joined
+func (f *FakeBackend) JoinConversation(channelID string) (*slack.Channel, string, []string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("JoinConversation"); err != nil {
+		return nil, "", nil, err
+	}
+
+	chn := f.channel(channelID)
+	if chn == nil {
+		return nil, "", nil, errors.New("channel_not_found")
+	}
+	if !chn.IsChannel {
+		return nil, "", nil, errors.New("method_not_supported_for_channel_type")
+	}
+	if chn.IsArchived {
+		return nil, "", nil, errors.New("is_archived")
+	}
+
+	var warning string
+	if chn.IsMember {
+		warning = "already_in_channel"
+	} else {
+		chn.IsMember = true
+		chn.NumMembers++
+	}
+
+	info := *chn
+	return &info, warning, nil, nil
+}
+
+// LeaveConversation implements Backend, the general channel can't be left
+func (f *FakeBackend) LeaveConversation(channelID string) (bool, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("LeaveConversation"); err != nil {
+		return false, err
+	}
+
+	chn := f.channel(channelID)
+	if chn == nil {
+		return false, errors.New("channel_not_found")
+	}
+	if chn.IsGeneral {
+		return false, errors.New("cant_leave_general")
+	}
+	if !chn.IsMember {
+		return true, nil
+	}
+
+	chn.IsMember = false
+	chn.NumMembers--
+
+	return false, nil
+}
+
+// CreateConversation implements Backend, the name has to be lowercase
+// without spaces or periods, like slack requires
+func (f *FakeBackend) CreateConversation(channelName string, isPrivate bool) (*slack.Channel, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("CreateConversation"); err != nil {
+		return nil, err
+	}
+
+	if channelName == "" || len(channelName) > 80 {
+		return nil, errors.New("invalid_name")
+	}
+	if strings.ContainsAny(channelName, " .") || strings.ToLower(channelName) != channelName {
+		return nil, errors.New("invalid_name_specials")
+	}
+
+	for _, chn := range f.Channels {
+		if chn.Name == channelName {
+			return nil, errors.New("name_taken")
+		}
+	}
+
+	chn := slack.Channel{IsChannel: !isPrivate, IsMember: true}
+	chn.ID = fmt.Sprintf("C%08d", len(f.Channels)+1)
+	chn.Name = channelName
+	chn.IsGroup = isPrivate
+	chn.IsPrivate = isPrivate
+	chn.IsOpen = true
+	chn.Creator = f.UserID
+	chn.NumMembers = 1
+	f.Channels = append(f.Channels, chn)
+
+	return &chn, nil
+}
+
+// ArchiveConversation implements Backend, the general channel can't be
+// archived
+func (f *FakeBackend) ArchiveConversation(channelID string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("ArchiveConversation"); err != nil {
+		return err
+	}
+
+	chn := f.channel(channelID)
+	if chn == nil {
+		return errors.New("channel_not_found")
+	}
+	if chn.IsGeneral {
+		return errors.New("cant_archive_general")
+	}
+	if chn.IsArchived {
+		return errors.New("already_archived")
+	}
+
+	chn.IsArchived = true
+
+	return nil
+}
+
+// SearchMessages implements Backend, it returns the messages that contain
+// every word of the query, newest first. The Page starts at 1.
+func (f *FakeBackend) SearchMessages(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("SearchMessages"); err != nil {
+		return nil, err
+	}
+
+	index := NewIndex()
+	for channelID, msgs := range f.Messages {
+		index.SetMessages(channelID, msgs)
+	}
+	found := index.Search(query, 0)
+
+	var matches []slack.SearchMessage
+	for _, match := range found {
+		msg := match.Message
+
+		var username string
+		for _, user := range f.Users {
+			if user.ID == msg.User {
+				username = user.Name
+			}
+		}
+
+		permalink := fmt.Sprintf(
+			"https://%s.slack.com/archives/%s/p%s",
+			f.Team, match.ChannelID, strings.Replace(msg.Timestamp, ".", "", 1),
+		)
+		if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
+			permalink += fmt.Sprintf(
+				"?thread_ts=%s&cid=%s", msg.ThreadTimestamp, match.ChannelID,
+			)
+		}
+
+		var channel slack.CtxChannel
+		if chn := f.channel(match.ChannelID); chn != nil {
+			channel = slack.CtxChannel{
+				ID:        chn.ID,
+				Name:      chn.Name,
+				IsMPIM:    chn.IsMpIM,
+				IsPrivate: chn.IsGroup,
+			}
+		}
+
+		matches = append(matches, slack.SearchMessage{
+			Type:      "message",
+			Channel:   channel,
+			User:      msg.User,
+			Username:  username,
+			Timestamp: msg.Timestamp,
+			Text:      msg.Text,
+			Permalink: permalink,
+		})
+	}
+
+	count := params.Count
+	if count <= 0 {
+		count = slack.DEFAULT_SEARCH_COUNT
+	}
+	page := params.Page
+	if page <= 0 {
+		page = 1
+	}
+	start, end, _ := paginate(len(matches), strconv.Itoa((page-1)*count), count)
+
+	result := &slack.SearchMessages{
+		Matches: matches[start:end],
+		Total:   len(matches),
+	}
+	result.Paging = slack.Paging{
+		Count: count,
+		Total: len(matches),
+		Page:  page,
+		Pages: (len(matches) + count - 1) / count,
+	}
+
+	return result, nil
+}
+
+// PostMessage implements Backend, the message is added to the channel and
+// delivered on the connection just like slack would do.
+func (f *FakeBackend) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	endpoint, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Slash commands are accepted, but don't have any effect
+	if strings.HasSuffix(endpoint, "chat.command") {
+		return channelID, "", nil
+	}
+
+	f.Lock()
+	if err := f.failure("PostMessage"); err != nil {
+		f.Unlock()
+		return "", "", err
+	}
+
+	if f.channel(channelID) == nil {
+		f.Unlock()
+		return "", "", errors.New("channel_not_found")
+	}
+
+	msg := slack.Message{Msg: slack.Msg{
+		Channel:         channelID,
+		User:            f.UserID,
+		Text:            values.Get("text"),
+		ThreadTimestamp: values.Get("thread_ts"),
+	}}
+
+	// Replies that are also sent to the channel show up in its history
+	if msg.ThreadTimestamp != "" && values.Get("reply_broadcast") == "true" {
+		msg.SubType = "thread_broadcast"
+	}
+
+	msg = f.addMessage(msg)
+	f.Unlock()
+
+	ev := slack.MessageEvent(msg)
+	f.Emit(slack.RTMEvent{Type: "message", Data: &ev})
+
+	return channelID, msg.Timestamp, nil
+}
+
+// UpdateMessage implements Backend, only the messages of the current user
+// can be updated. The change is delivered as a message_changed event.
+func (f *FakeBackend) UpdateMessage(channelID string, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	f.Lock()
+	if err := f.failure("UpdateMessage"); err != nil {
+		f.Unlock()
+		return "", "", "", err
+	}
+
+	msg := f.message(channelID, timestamp)
+	if msg == nil {
+		f.Unlock()
+		return "", "", "", errors.New("message_not_found")
+	}
+
+	if msg.User != f.UserID {
+		f.Unlock()
+		return "", "", "", errors.New("cant_update_message")
+	}
+
+	previous := msg.Msg
+	msg.Text = values.Get("text")
+	msg.Edited = &slack.Edited{
+		User:      f.UserID,
+		Timestamp: timestamp,
+	}
+	current := msg.Msg
+	f.Unlock()
+
+	ev := slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:    "message",
+			SubType: "message_changed",
+			Channel: channelID,
+			Hidden:  true,
+		},
+		SubMessage:      &current,
+		PreviousMessage: &previous,
+	}
+	f.Emit(slack.RTMEvent{Type: "message", Data: &ev})
+
+	return channelID, timestamp, current.Text, nil
+}
+
+// DeleteMessage implements Backend, only the messages of the current user
+// can be deleted. The deletion is delivered as a message_deleted event.
+func (f *FakeBackend) DeleteMessage(channelID string, timestamp string) (string, string, error) {
+	f.Lock()
+	if err := f.failure("DeleteMessage"); err != nil {
+		f.Unlock()
+		return "", "", err
+	}
+
+	msg := f.message(channelID, timestamp)
+	if msg == nil {
+		f.Unlock()
+		return "", "", errors.New("message_not_found")
+	}
+
+	if msg.User != f.UserID {
+		f.Unlock()
+		return "", "", errors.New("cant_delete_message")
+	}
+
+	previous := msg.Msg
+
+	var msgs []slack.Message
+	for _, m := range f.Messages[channelID] {
+		if m.Timestamp == timestamp {
+			continue
+		}
+
+		// Remove the reply from its parent
+		if m.Timestamp == previous.ThreadTimestamp {
+			var replies []slack.Reply
+			for _, r := range m.Replies {
+				if r.Timestamp != timestamp {
+					replies = append(replies, r)
+				}
+			}
+			m.Replies = replies
+			m.ReplyCount = len(replies)
+		}
+
+		msgs = append(msgs, m)
+	}
+	f.Messages[channelID] = msgs
+	f.Unlock()
+
+	ev := slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:             "message",
+			SubType:          "message_deleted",
+			Channel:          channelID,
+			Hidden:           true,
+			DeletedTimestamp: timestamp,
+		},
+		PreviousMessage: &previous,
+	}
+	f.Emit(slack.RTMEvent{Type: "message", Data: &ev})
+
+	return channelID, timestamp, nil
+}
+
+// AddReaction implements Backend, the reaction is delivered as a
+// reaction_added event
+func (f *FakeBackend) AddReaction(name string, item slack.ItemRef) error {
+	f.Lock()
+	if err := f.failure("AddReaction"); err != nil {
+		f.Unlock()
+		return err
+	}
+
+	msg := f.message(item.Channel, item.Timestamp)
+	if msg == nil {
+		f.Unlock()
+		return errors.New("message_not_found")
+	}
+
+	found := false
+	for i, r := range msg.Reactions {
+		if r.Name != name {
+			continue
+		}
+
+		for _, user := range r.Users {
+			if user == f.UserID {
+				f.Unlock()
+				return errors.New("already_reacted")
+			}
+		}
+
+		msg.Reactions[i].Count++
+		msg.Reactions[i].Users = append(r.Users, f.UserID)
+		found = true
+	}
+
+	if !found {
+		msg.Reactions = append(msg.Reactions, slack.ItemReaction{
+			Name:  name,
+			Count: 1,
+			Users: []string{f.UserID},
+		})
+	}
+
+	ev := slack.ReactionAddedEvent{
+		Type:     "reaction_added",
+		User:     f.UserID,
+		ItemUser: msg.User,
+		Reaction: name,
+	}
+	f.Unlock()
+
+	ev.Item.Type = "message"
+	ev.Item.Channel = item.Channel
+	ev.Item.Timestamp = item.Timestamp
+	f.Emit(slack.RTMEvent{Type: "reaction_added", Data: &ev})
+
+	return nil
+}
+
+// RemoveReaction implements Backend, the removal is delivered as a
+// reaction_removed event
+func (f *FakeBackend) RemoveReaction(name string, item slack.ItemRef) error {
+	f.Lock()
+	if err := f.failure("RemoveReaction"); err != nil {
+		f.Unlock()
+		return err
+	}
+
+	msg := f.message(item.Channel, item.Timestamp)
+	if msg == nil {
+		f.Unlock()
+		return errors.New("message_not_found")
+	}
+
+	found := false
+	var reactions []slack.ItemReaction
+	for _, r := range msg.Reactions {
+		if r.Name == name {
+			var users []string
+			for _, user := range r.Users {
+				if user == f.UserID {
+					found = true
+					continue
+				}
+				users = append(users, user)
+			}
+
+			r.Users = users
+			r.Count = len(users)
+		}
+
+		if r.Count > 0 {
+			reactions = append(reactions, r)
+		}
+	}
+
+	if !found {
+		f.Unlock()
+		return errors.New("no_reaction")
+	}
+
+	msg.Reactions = reactions
+
+	ev := slack.ReactionRemovedEvent{
+		Type:     "reaction_removed",
+		User:     f.UserID,
+		ItemUser: msg.User,
+		Reaction: name,
+	}
+	f.Unlock()
+
+	ev.Item.Type = "message"
+	ev.Item.Channel = item.Channel
+	ev.Item.Timestamp = item.Timestamp
+	f.Emit(slack.RTMEvent{Type: "reaction_removed", Data: &ev})
+
+	return nil
+}
+
+// SetChannelReadMark implements Backend
+func (f *FakeBackend) SetChannelReadMark(channelID, ts string) error {
+	return f.markAsRead("SetChannelReadMark", channelID, ts)
+}
+
+// SetGroupReadMark implements Backend
+func (f *FakeBackend) SetGroupReadMark(groupID, ts string) error {
+	return f.markAsRead("SetGroupReadMark", groupID, ts)
+}
+
+// MarkIMChannel implements Backend
+func (f *FakeBackend) MarkIMChannel(channelID, ts string) error {
+	return f.markAsRead("MarkIMChannel", channelID, ts)
+}
+
+// GetNotificationPrefs implements Backend
+func (f *FakeBackend) GetNotificationPrefs() (*NotificationPrefs, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure("GetNotificationPrefs"); err != nil {
+		return nil, err
+	}
+
+	prefs := &NotificationPrefs{
+		MutedChannels: append([]string{}, f.Prefs.MutedChannels...),
+		Levels:        make(map[string]string),
+	}
+	for channelID, level := range f.Prefs.Levels {
+		prefs.Levels[channelID] = level
+	}
+
+	return prefs, nil
+}
+
+// Connect implements Backend, it will start delivering the events of the
+// Script. Scripted message events are added to the workspace when they are
+// delivered. When it is called again after Disconnect, the connection is
+// restored and the Script isn't delivered again.
+func (f *FakeBackend) Connect() chan slack.RTMEvent {
+	f.Lock()
+	if f.events == nil {
+		f.events = make(chan slack.RTMEvent, 50)
+	}
+	f.offline = false
+	f.connections++
+	connections := f.connections
+
+	script := make([]FakeEvent, len(f.Script))
+	copy(script, f.Script)
+	f.Unlock()
+
+	f.Emit(slack.RTMEvent{
+		Type: "connected",
+		Data: &slack.ConnectedEvent{ConnectionCount: connections},
+	})
+
+	if connections > 1 {
+		return f.events
+	}
+
+	sort.SliceStable(script, func(i, j int) bool {
+		return script[i].Delay < script[j].Delay
+	})
+
+	go func() {
+		var elapsed time.Duration
+		for _, fe := range script {
+			time.Sleep(fe.Delay - elapsed)
+			elapsed = fe.Delay
+
+			if ev, ok := fe.Event.Data.(*slack.MessageEvent); ok {
+				f.Lock()
+				*ev = slack.MessageEvent(f.addMessage(slack.Message(*ev)))
+				f.Unlock()
+			}
+
+			f.Emit(fe.Event)
+		}
+	}()
+
+	return f.events
+}
+
+// Disconnect will drop the connection, the events that happen until
+// Reconnect is called are not delivered, just like with a real outage.
+// The slacktest Server closes the websocket, the RTM will then reconnect
+// by itself.
+func (f *FakeBackend) Disconnect() {
+	f.Emit(slack.RTMEvent{
+		Type: "disconnected",
+		Data: &slack.DisconnectedEvent{Cause: errors.New("connection lost")},
+	})
+
+	f.Lock()
+	f.offline = true
+	f.Unlock()
+}
+
+// IsConnected returns whether the events are delivered, which is the case
+// after Connect until Disconnect is called
+func (f *FakeBackend) IsConnected() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.events != nil && !f.offline
+}
+
+// Reconnect will restore the connection after Disconnect
+func (f *FakeBackend) Reconnect() {
+	f.Lock()
+	f.offline = false
+	connections := f.connections
+	f.Unlock()
+
+	f.Emit(slack.RTMEvent{
+		Type: "connecting",
+		Data: &slack.ConnectingEvent{Attempt: 1, ConnectionCount: connections},
+	})
+
+	f.Connect()
+}
+
+func (f *FakeBackend) messageEvent(msg slack.Message) slack.RTMEvent {
+	ev := slack.MessageEvent(msg)
+	ev.Type = "message"
+
+	return slack.RTMEvent{Type: "message", Data: &ev}
+}
+
+// addMessage will give the message a timestamp, add it to the channel and
+// update the thread information of the parent. The lock must be held.
+func (f *FakeBackend) addMessage(msg slack.Message) slack.Message {
+	// Seeded messages are a minute apart, timestamps always increase
+	next := f.clock.Add(time.Minute)
+	if now := time.Now(); next.After(now) {
+		next = now
+	}
+	if !next.After(f.clock) {
+		next = f.clock.Add(time.Microsecond)
+	}
+	f.clock = next
+
+	msg.Type = "message"
+	msg.Timestamp = fmt.Sprintf("%d.%06d", f.clock.Unix(), f.clock.Nanosecond()/1000)
+
+	msgs := f.Messages[msg.Channel]
+	if msg.ThreadTimestamp != "" {
+		for i := range msgs {
+			if msgs[i].Timestamp == msg.ThreadTimestamp {
+				msgs[i].ThreadTimestamp = msgs[i].Timestamp
+				msgs[i].ReplyCount++
+				msgs[i].Replies = append(msgs[i].Replies, slack.Reply{
+					User:      msg.User,
+					Timestamp: msg.Timestamp,
+				})
+			}
+		}
+	}
+
+	f.Messages[msg.Channel] = append(msgs, msg)
+
+	if chn := f.channel(msg.Channel); chn != nil {
+		if msg.User == f.UserID {
+			chn.LastRead = msg.Timestamp
+			chn.UnreadCount = 0
+			chn.UnreadCountDisplay = 0
+		} else {
+			chn.UnreadCount++
+			chn.UnreadCountDisplay++
+		}
+	}
+
+	return msg
+}
+
+// markAsRead will set the last read message of the channel, the method is
+// the name that its failures are set by
+func (f *FakeBackend) markAsRead(method string, channelID string, ts string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.failure(method); err != nil {
+		return err
+	}
+
+	chn := f.channel(channelID)
+	if chn == nil {
+		return errors.New("channel_not_found")
+	}
+
+	chn.LastRead = ts
+	chn.UnreadCount = 0
+	chn.UnreadCountDisplay = 0
+
+	return nil
+}
+
+// message returns the message with the timestamp in the channel, the lock
+// must be held
+func (f *FakeBackend) message(channelID string, timestamp string) *slack.Message {
+	msgs := f.Messages[channelID]
+	for i := range msgs {
+		if msgs[i].Timestamp == timestamp {
+			return &msgs[i]
+		}
+	}
+	return nil
+}
+
+// channel returns the channel with the ID, the lock must be held
+func (f *FakeBackend) channel(channelID string) *slack.Channel {
+	for i := range f.Channels {
+		if f.Channels[i].ID == channelID {
+			return &f.Channels[i]
+		}
+	}
+	return nil
+}
+
+// paginate returns the bounds of the page that starts at the cursor, and
+// the cursor of the next page
+func paginate(total int, cursor string, limit int) (int, int, string) {
+	start, err := strconv.Atoi(cursor)
+	if err != nil || start < 0 || start > total {
+		start = 0
+	}
+
+	end := total
+	if limit > 0 && start+limit < total {
+		end = start + limit
+	}
+
+	var next string
+	if end < total {
+		next = strconv.Itoa(end)
+	}
+
+	return start, end, next
+}
+
+// inRange checks if the timestamp ts is between oldest and latest, when
+// they are set
+func inRange(ts string, oldest string, latest string, inclusive bool) bool {
+	t, _ := strconv.ParseFloat(ts, 64)
+
+	if oldest != "" {
+		o, _ := strconv.ParseFloat(oldest, 64)
+		if t < o || (t == o && !inclusive) {
+			return false
+		}
+	}
+
+	if latest != "" {
+		l, _ := strconv.ParseFloat(latest, 64)
+		if t > l || (t == l && !inclusive) {
+			return false
+		}
+	}
+
+	return true
+}
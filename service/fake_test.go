@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/config"
+)
+
+// newFakeService returns a SlackService that is connected to a new
+// FakeBackend
+func newFakeService(t *testing.T) (*SlackService, *FakeBackend) {
+	t.Helper()
+
+	fake := NewFakeBackend()
+	cfg := &config.Config{}
+	svc, err := NewSlackService(cfg, cfg.GetWorkspaces()[0], fake)
+	if err != nil {
+		t.Fatalf("couldn't create the service: %s", err)
+	}
+
+	return svc, fake
+}
+
+// nextEvent returns the next event of the type, the events before it are
+// skipped
+func nextEvent(t *testing.T, events chan slack.RTMEvent, eventType string) slack.RTMEvent {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == eventType {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event was received", eventType)
+		}
+	}
+}
+
+// noEvent checks that there is no event of the type
+func noEvent(t *testing.T, events chan slack.RTMEvent, eventType string) {
+	t.Helper()
+
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == eventType {
+				t.Fatalf("unexpected %s event: %+v", eventType, ev.Data)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func TestGetMessagesPagination(t *testing.T) {
+	svc, _ := newFakeService(t)
+
+	msgs, _, err := svc.GetMessages("C00000002", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 20 {
+		t.Fatalf("expected 20 messages, got %d", len(msgs))
+	}
+
+	// The newest message is in the last place
+	if got := msgs[len(msgs)-1].Content; got != "Look at this cat" {
+		t.Errorf("expected the newest message last, got %q", got)
+	}
+
+	// The pages of older messages make up the whole history, without
+	// the thread replies
+	seen := make(map[string]bool)
+	for _, msg := range msgs {
+		seen[msg.ID] = true
+	}
+
+	oldest := msgs[0].ID
+	pages := 1
+	for {
+		older, _, hasMore, err := svc.GetOlderMessages("C00000002", oldest, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+
+		for _, msg := range older {
+			if seen[msg.ID] {
+				t.Fatalf("message %s is on more than one page", msg.ID)
+			}
+			seen[msg.ID] = true
+		}
+
+		if len(older) > 0 {
+			oldest = older[0].ID
+		}
+		if !hasMore {
+			break
+		}
+		if pages > 10 {
+			t.Fatal("the pages of the history don't end")
+		}
+	}
+
+	if len(seen) != 102 {
+		t.Errorf("expected 102 messages in the history, got %d", len(seen))
+	}
+	if pages != 6 {
+		t.Errorf("expected 6 pages, got %d", pages)
+	}
+
+	// Newer messages than the oldest page start right after it
+	newer, _, hasMore, err := svc.GetNewerMessages("C00000002", oldest, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newer) != 5 || !hasMore {
+		t.Errorf("expected 5 newer messages and more, got %d and %t", len(newer), hasMore)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	svc, _ := newFakeService(t)
+
+	if err := svc.SendMessage("C00000001", "Hello <world>"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message is delivered as an event, and added to the history
+	ev := nextEvent(t, svc.IncomingEvents, "message")
+	msg := ev.Data.(*slack.MessageEvent)
+	if msg.Channel != "C00000001" || msg.User != svc.CurrentUserID {
+		t.Errorf("unexpected message event: %+v", msg)
+	}
+
+	msgs, _, err := svc.GetMessages("C00000001", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Content != "Hello <world>" {
+		t.Errorf("expected the sent message in the history, got %+v", msgs)
+	}
+
+	// A reply is only added to the thread
+	parent := msgs[0].ID
+	if err := svc.SendReply("C00000001", parent, "In the thread", false); err != nil {
+		t.Fatal(err)
+	}
+
+	_, replies, _, err := svc.GetThread("C00000001", parent, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || replies[0].Content != "In the thread" {
+		t.Errorf("expected the reply in the thread, got %+v", replies)
+	}
+
+	if err := svc.SendMessage("C99999999", "Nobody home"); err == nil {
+		t.Error("expected an error for an unknown channel")
+	}
+}
+
+func TestUpdateMessageText(t *testing.T) {
+	svc, fake := newFakeService(t)
+
+	// The text as slack formats it, edited without changes it is sent
+	// back as it is
+	text := "See <https://example.com/?a=1&amp;b=2|the docs> in <#C00000002|random>, " +
+		"<!subteam^S00000001|@team> and <@U00000002> :tada: &lt;3 &amp; more"
+	fake.AddMessage("C00000001", svc.CurrentUserID, text, "")
+
+	msgs, _, err := svc.GetMessages("C00000001", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Text != text {
+		t.Fatalf("expected the text of slack on the message, got %+v", msgs)
+	}
+
+	edited := EditableText(msgs[0].Text)
+	if err := svc.UpdateMessage("C00000001", msgs[0].ID, edited); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := fake.GetConversationHistory(&slack.GetConversationHistoryParameters{
+		ChannelID: "C00000001",
+		Limit:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history.Messages[0].Text; got != text {
+		t.Errorf("the text was changed by editing it:\nexpected %s\ngot      %s", text, got)
+	}
+
+	// The text that is typed is still escaped
+	if err := svc.UpdateMessage("C00000001", msgs[0].ID, "1 < 2 & <https://example.com>"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = fake.GetConversationHistory(&slack.GetConversationHistoryParameters{
+		ChannelID: "C00000001",
+		Limit:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := history.Messages[0].Text, "1 &lt; 2 &amp; <https://example.com>"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestMarkAsRead(t *testing.T) {
+	svc, _ := newFakeService(t)
+
+	unread, err := svc.GetUnread("D00000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unread.Unread || unread.Count != 1 {
+		t.Fatalf("expected 1 unread message, got %+v", unread)
+	}
+
+	svc.MarkAsRead(components.ChannelItem{
+		ID:   "D00000001",
+		Type: components.ChannelTypeIM,
+	})
+
+	unread, err = svc.GetUnread("D00000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread.Unread || unread.Count != 0 {
+		t.Errorf("expected no unread messages, got %+v", unread)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	svc, fake := newFakeService(t)
+
+	fake.FailNext("GetConversationHistory", errors.New("first"), errors.New("second"))
+
+	for _, expected := range []string{"first", "second"} {
+		_, _, err := svc.GetMessages("C00000001", 10)
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected the %q error, got %v", expected, err)
+		}
+	}
+
+	// The errors are only returned once
+	if _, _, err := svc.GetMessages("C00000001", 10); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// The errors of other methods aren't affected
+	fake.FailNext("GetConversationInfo", errors.New("info"))
+	if _, _, err := svc.GetMessages("C00000001", 10); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if _, err := svc.GetUnread("C00000001"); err == nil {
+		t.Error("expected the error of GetConversationInfo")
+	}
+}
+
+func TestDisconnectReconnect(t *testing.T) {
+	svc, fake := newFakeService(t)
+	nextEvent(t, svc.IncomingEvents, "connected")
+
+	fake.Disconnect()
+	nextEvent(t, svc.IncomingEvents, "disconnected")
+
+	// Events aren't delivered while we're disconnected, the requests
+	// still succeed
+	if err := svc.SendMessage("C00000001", "Into the void"); err != nil {
+		t.Fatal(err)
+	}
+	noEvent(t, svc.IncomingEvents, "message")
+
+	fake.Reconnect()
+	nextEvent(t, svc.IncomingEvents, "connecting")
+	ev := nextEvent(t, svc.IncomingEvents, "connected")
+	if count := ev.Data.(*slack.ConnectedEvent).ConnectionCount; count != 2 {
+		t.Errorf("expected the second connection, got %d", count)
+	}
+
+	if err := svc.SendMessage("C00000001", "Back again"); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, svc.IncomingEvents, "message")
+}
+
+func TestEmitFullConnection(t *testing.T) {
+	_, fake := newFakeService(t)
+
+	// Nothing reads the events, the messages are still posted once the
+	// connection is full
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _, err := fake.PostMessage("C00000001", slack.MsgOptionText("Is anybody there?", false))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("posting a message blocked on the full connection")
+	}
+}
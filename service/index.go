@@ -0,0 +1,128 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/slack-go/slack"
+)
+
+// Index is a full-text index of messages, it maps the words of the
+// messages to the messages in which they appear. It is used to search the
+// cached messages when slack can't be reached.
+type Index struct {
+	mutex sync.RWMutex
+
+	words    map[string]map[indexKey]bool
+	messages map[indexKey]slack.Message
+	channels map[string][]indexKey
+}
+
+// indexKey identifies a message in the Index
+type indexKey struct {
+	channelID string
+	timestamp string
+}
+
+// IndexMatch is a message that matched a search of the Index
+type IndexMatch struct {
+	ChannelID string
+	Message   slack.Message
+}
+
+// NewIndex is the constructor for the Index
+func NewIndex() *Index {
+	return &Index{
+		words:    make(map[string]map[indexKey]bool),
+		messages: make(map[indexKey]slack.Message),
+		channels: make(map[string][]indexKey),
+	}
+}
+
+// SetMessages will replace the messages of the channel in the Index
+func (i *Index) SetMessages(channelID string, messages []slack.Message) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, key := range i.channels[channelID] {
+		for _, word := range tokenize(i.messages[key].Text) {
+			delete(i.words[word], key)
+			if len(i.words[word]) == 0 {
+				delete(i.words, word)
+			}
+		}
+		delete(i.messages, key)
+	}
+	delete(i.channels, channelID)
+
+	for _, msg := range messages {
+		key := indexKey{channelID: channelID, timestamp: msg.Timestamp}
+
+		i.messages[key] = msg
+		i.channels[channelID] = append(i.channels[channelID], key)
+
+		for _, word := range tokenize(msg.Text) {
+			if i.words[word] == nil {
+				i.words[word] = make(map[indexKey]bool)
+			}
+			i.words[word][key] = true
+		}
+	}
+}
+
+// Search returns the messages that contain every word of the query, a word
+// matches the words that it is a prefix of. The newest messages are
+// returned first, delimited by a count.
+func (i *Index) Search(query string, count int) []IndexMatch {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var keys map[indexKey]bool
+	for _, term := range terms {
+		found := make(map[indexKey]bool)
+		for word, wordKeys := range i.words {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+
+			for key := range wordKeys {
+				if keys == nil || keys[key] {
+					found[key] = true
+				}
+			}
+		}
+		keys = found
+	}
+
+	var matches []IndexMatch
+	for key := range keys {
+		matches = append(matches, IndexMatch{
+			ChannelID: key.channelID,
+			Message:   i.messages[key],
+		})
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		return matches[a].Message.Timestamp > matches[b].Message.Timestamp
+	})
+
+	if count > 0 && len(matches) > count {
+		matches = matches[:count]
+	}
+
+	return matches
+}
+
+// tokenize will split the text into its lowercased words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,642 @@
+package service
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Priority determines the order in which queued requests are sent, the
+// requests the user is waiting on go before the ones done in the
+// background
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+)
+
+// Tier is the rate limit of a slack api method, the amount of requests that
+// are allowed per minute and how many of them can be sent in a burst.
+//
+// https://api.slack.com/docs/rate-limits
+type Tier struct {
+	PerMinute int
+	Burst     int
+}
+
+var (
+	Tier2       = Tier{PerMinute: 20, Burst: 5}
+	Tier3       = Tier{PerMinute: 50, Burst: 10}
+	Tier4       = Tier{PerMinute: 100, Burst: 20}
+	TierSpecial = Tier{PerMinute: 60, Burst: 3}
+)
+
+// methodLimit is the rate limit tier and the priority of a slack api method
+type methodLimit struct {
+	Tier     Tier
+	Priority Priority
+}
+
+// methodLimits contains the limits of the slack api methods that are used
+// by the Backend, a method that isn't present is sent as a Tier 3 method
+// with a normal priority.
+//
+// Sending, changing and reacting to messages is what the user waits on,
+// the history and the users are needed to show the messages, and the
+// presences and read marks are done in the background.
+var methodLimits = map[string]methodLimit{
+	"auth.test":             {Tier4, PriorityHigh},
+	"users.list":            {Tier2, PriorityNormal},
+	"users.info":            {Tier4, PriorityNormal},
+	"users.getPresence":     {Tier3, PriorityLow},
+	"users.setPresence":     {Tier2, PriorityLow},
+	"usergroups.list":       {Tier2, PriorityLow},
+	"bots.info":             {Tier3, PriorityNormal},
+	"conversations.list":    {Tier2, PriorityNormal},
+	"conversations.info":    {Tier3, PriorityLow},
+	"conversations.history": {Tier3, PriorityHigh},
+	"conversations.replies": {Tier3, PriorityNormal},
+	"conversations.open":    {Tier3, PriorityHigh},
+	"conversations.join":    {Tier3, PriorityHigh},
+	"conversations.leave":   {Tier3, PriorityHigh},
+	"conversations.create":  {Tier2, PriorityHigh},
+	"conversations.archive": {Tier2, PriorityHigh},
+	"search.messages":       {Tier2, PriorityHigh},
+	"chat.postMessage":      {TierSpecial, PriorityHigh},
+	"chat.update":           {Tier3, PriorityHigh},
+	"chat.delete":           {Tier3, PriorityHigh},
+	"reactions.add":         {Tier3, PriorityHigh},
+	"reactions.remove":      {Tier2, PriorityHigh},
+	"channels.mark":         {Tier3, PriorityLow},
+	"groups.mark":           {Tier3, PriorityLow},
+	"im.mark":               {Tier3, PriorityLow},
+	"users.prefs.get":       {Tier3, PriorityNormal},
+}
+
+const (
+	// maxRequests is the amount of requests that are sent at the same
+	// time, the other requests are queued by their priority
+	maxRequests = 4
+
+	// maxRateLimitRetries is the amount of times a request is retried when
+	// it was rate limited, after that the error is returned so that the
+	// user is notified
+	maxRateLimitRetries = 3
+)
+
+// SchedulerMetrics contains the statistics of the requests to a slack api
+// method
+type SchedulerMetrics struct {
+	Method      string
+	Calls       int
+	Errors      int
+	RateLimited int
+	Queued      int
+	Wait        time.Duration
+	MaxWait     time.Duration
+}
+
+// AverageWait returns the average time a request had to wait before it
+// was sent
+func (m SchedulerMetrics) AverageWait() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+
+	return m.Wait / time.Duration(m.Calls)
+}
+
+// Scheduler is a Backend that sends the requests of the Backend it wraps
+// within the rate limits of slack. The requests to a method are spread
+// out according to its tier, at most maxRequests are sent at the same
+// time, and when slack responds with a rate limit the method is paused
+// for the duration slack asks for. Both while waiting for the rate limit
+// and for a request slot, the requests go by their priority.
+type Scheduler struct {
+	Backend
+	*schedule
+
+	// priority overrides the priority of the methods, when it is set
+	priority *Priority
+}
+
+// schedule is the state of a Scheduler, it is shared with the Schedulers
+// that are returned by WithPriority
+type schedule struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	metrics map[string]*SchedulerMetrics
+	queue   requestQueue
+	running int
+	seq     int
+}
+
+// NewScheduler is the constructor for the Scheduler, it wraps the backend
+func NewScheduler(backend Backend) *Scheduler {
+	return &Scheduler{
+		Backend: backend,
+		schedule: &schedule{
+			buckets: make(map[string]*bucket),
+			metrics: make(map[string]*SchedulerMetrics),
+		},
+	}
+}
+
+// WithPriority returns a Scheduler that sends all of its requests with the
+// priority, within the same rate limits and request slots as s. Use it for
+// requests of which the priority differs from the one of the method, e.g.
+// the ones done in the background.
+func (s *Scheduler) WithPriority(priority Priority) *Scheduler {
+	return &Scheduler{
+		Backend:  s.Backend,
+		schedule: s.schedule,
+		priority: &priority,
+	}
+}
+
+// Metrics returns the statistics of the methods that were requested,
+// sorted by the name of the method
+func (s *Scheduler) Metrics() []SchedulerMetrics {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	metrics := make([]SchedulerMetrics, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		metrics = append(metrics, *m)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Method < metrics[j].Method
+	})
+
+	return metrics
+}
+
+// do will send the request fn for the slack api method, once the rate
+// limit of the method allows it and a request slot is available
+func (s *Scheduler) do(method string, fn func() error) error {
+	limit, ok := methodLimits[method]
+	if !ok {
+		limit = methodLimit{Tier3, PriorityNormal}
+	}
+
+	if s.priority != nil {
+		limit.Priority = *s.priority
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+
+		s.mutex.Lock()
+		b, ok := s.buckets[method]
+		if !ok {
+			b = newBucket(limit.Tier, start)
+			s.buckets[method] = b
+		}
+		ready := s.reserve(b, limit.Priority, start)
+		m := s.metric(method)
+		m.Queued++
+		s.mutex.Unlock()
+
+		<-ready
+
+		s.acquire(limit.Priority)
+		waited := time.Since(start)
+		err := fn()
+		s.release()
+
+		s.mutex.Lock()
+		m.Queued--
+		m.Calls++
+		m.Wait += waited
+		if waited > m.MaxWait {
+			m.MaxWait = waited
+		}
+		if err != nil {
+			m.Errors++
+		}
+		rateLimited, isRateLimited := err.(*slack.RateLimitedError)
+		if isRateLimited {
+			m.RateLimited++
+			s.pause(b, time.Now(), rateLimited.RetryAfter)
+		}
+		s.mutex.Unlock()
+
+		if isRateLimited && attempt < maxRateLimitRetries {
+			continue
+		}
+
+		return err
+	}
+}
+
+// metric returns the metrics of the method, s.mutex must be held
+func (s *Scheduler) metric(method string) *SchedulerMetrics {
+	m, ok := s.metrics[method]
+	if !ok {
+		m = &SchedulerMetrics{Method: method}
+		s.metrics[method] = m
+	}
+
+	return m
+}
+
+// reserve will take a token from the bucket for a request, the returned
+// channel is closed when the request can be sent. When the bucket is empty
+// the request is queued by its priority, the queued requests are handed
+// the tokens as the bucket is refilled. s.mutex must be held.
+func (s *Scheduler) reserve(b *bucket, priority Priority, now time.Time) <-chan struct{} {
+	s.seq++
+	r := &request{
+		priority: priority,
+		seq:      s.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&b.queue, r)
+
+	s.dispatch(b, now)
+
+	return r.ready
+}
+
+// dispatch will hand the tokens of the bucket to the queued requests, and
+// when requests are left it is executed again once the next token is
+// available. s.mutex must be held.
+func (s *Scheduler) dispatch(b *bucket, now time.Time) {
+	b.refill(now)
+	for b.queue.Len() > 0 && b.tokens >= 1 {
+		r := heap.Pop(&b.queue).(*request)
+		b.tokens--
+		close(r.ready)
+	}
+
+	if b.queue.Len() == 0 || b.timer != nil {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(b.next(now), func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if b.timer == timer {
+			b.timer = nil
+		}
+		s.dispatch(b, time.Now())
+	})
+	b.timer = timer
+}
+
+// pause will pause the bucket for the duration, the queued requests wait
+// until it has passed. s.mutex must be held.
+func (s *Scheduler) pause(b *bucket, now time.Time, d time.Duration) {
+	b.pause(now, d)
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	s.dispatch(b, now)
+}
+
+// acquire will wait until a request slot is available, when the slots
+// are taken the request is queued by its priority
+func (s *Scheduler) acquire(priority Priority) {
+	s.mutex.Lock()
+	if s.running < maxRequests && s.queue.Len() == 0 {
+		s.running++
+		s.mutex.Unlock()
+		return
+	}
+
+	s.seq++
+	r := &request{
+		priority: priority,
+		seq:      s.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&s.queue, r)
+	s.mutex.Unlock()
+
+	<-r.ready
+}
+
+// release will hand the request slot to the next queued request
+func (s *Scheduler) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.queue.Len() > 0 {
+		r := heap.Pop(&s.queue).(*request)
+		close(r.ready)
+		return
+	}
+
+	s.running--
+}
+
+// bucket is a token bucket that holds the requests that can be sent for a
+// method, it is refilled at the rate of the tier of the method
+type bucket struct {
+	tier   Tier
+	tokens float64
+
+	// last is the time the bucket was last refilled, it is set in the
+	// future when the method is paused
+	last time.Time
+
+	// queue holds the requests that wait for a token, and timer hands
+	// them out once the next token is available
+	queue requestQueue
+	timer *time.Timer
+}
+
+func newBucket(tier Tier, now time.Time) *bucket {
+	return &bucket{
+		tier:   tier,
+		tokens: float64(tier.Burst),
+		last:   now,
+	}
+}
+
+// refill will add the tokens that have become available since the bucket
+// was last refilled
+func (b *bucket) refill(now time.Time) {
+	if !now.After(b.last) {
+		return
+	}
+
+	rate := float64(b.tier.PerMinute) / time.Minute.Seconds()
+	b.tokens = math.Min(
+		float64(b.tier.Burst),
+		b.tokens+now.Sub(b.last).Seconds()*rate,
+	)
+	b.last = now
+}
+
+// next returns how long it takes before the next token is available
+func (b *bucket) next(now time.Time) time.Duration {
+	rate := float64(b.tier.PerMinute) / time.Minute.Seconds()
+
+	wait := b.last.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+
+	if b.tokens < 1 {
+		wait += time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+
+	return wait
+}
+
+// pause will stop the bucket from being refilled until the duration has
+// passed, and empties it so that the requests don't burst afterwards
+func (b *bucket) pause(now time.Time, d time.Duration) {
+	if until := now.Add(d); until.After(b.last) {
+		b.last = until
+	}
+
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+}
+
+// request is a request that waits for a token or a slot, it is notified by
+// closing the ready channel
+type request struct {
+	priority Priority
+	seq      int
+	ready    chan struct{}
+}
+
+// requestQueue is a priority queue of requests, the requests with the
+// same priority are kept in the order they were queued
+type requestQueue []*request
+
+func (q requestQueue) Len() int { return len(q) }
+
+func (q requestQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+
+	return q[i].seq < q[j].seq
+}
+
+func (q requestQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *requestQueue) Push(x interface{}) {
+	*q = append(*q, x.(*request))
+}
+
+func (q *requestQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	r := old[n-1]
+	*q = old[:n-1]
+	return r
+}
+
+// The methods of the Backend, every request is sent through do with the
+// name of the slack api method
+
+func (s *Scheduler) AuthTest() (resp *slack.AuthTestResponse, err error) {
+	err = s.do("auth.test", func() error {
+		resp, err = s.Backend.AuthTest()
+		return err
+	})
+	return resp, err
+}
+
+func (s *Scheduler) GetUsers() (users []slack.User, err error) {
+	err = s.do("users.list", func() error {
+		users, err = s.Backend.GetUsers()
+		return err
+	})
+	return users, err
+}
+
+func (s *Scheduler) GetUserInfo(user string) (info *slack.User, err error) {
+	err = s.do("users.info", func() error {
+		info, err = s.Backend.GetUserInfo(user)
+		return err
+	})
+	return info, err
+}
+
+func (s *Scheduler) GetBotInfo(bot string) (info *slack.Bot, err error) {
+	err = s.do("bots.info", func() error {
+		info, err = s.Backend.GetBotInfo(bot)
+		return err
+	})
+	return info, err
+}
+
+func (s *Scheduler) GetUserPresence(user string) (presence *slack.UserPresence, err error) {
+	err = s.do("users.getPresence", func() error {
+		presence, err = s.Backend.GetUserPresence(user)
+		return err
+	})
+	return presence, err
+}
+
+func (s *Scheduler) GetUserGroups(options ...slack.GetUserGroupsOption) (groups []slack.UserGroup, err error) {
+	err = s.do("usergroups.list", func() error {
+		groups, err = s.Backend.GetUserGroups(options...)
+		return err
+	})
+	return groups, err
+}
+
+func (s *Scheduler) SetUserPresence(presence string) error {
+	return s.do("users.setPresence", func() error {
+		return s.Backend.SetUserPresence(presence)
+	})
+}
+
+func (s *Scheduler) GetConversations(params *slack.GetConversationsParameters) (channels []slack.Channel, cursor string, err error) {
+	err = s.do("conversations.list", func() error {
+		channels, cursor, err = s.Backend.GetConversations(params)
+		return err
+	})
+	return channels, cursor, err
+}
+
+func (s *Scheduler) GetConversationInfo(channelID string, includeLocale bool) (channel *slack.Channel, err error) {
+	err = s.do("conversations.info", func() error {
+		channel, err = s.Backend.GetConversationInfo(channelID, includeLocale)
+		return err
+	})
+	return channel, err
+}
+
+func (s *Scheduler) GetConversationHistory(params *slack.GetConversationHistoryParameters) (resp *slack.GetConversationHistoryResponse, err error) {
+	err = s.do("conversations.history", func() error {
+		resp, err = s.Backend.GetConversationHistory(params)
+		return err
+	})
+	return resp, err
+}
+
+func (s *Scheduler) GetConversationReplies(params *slack.GetConversationRepliesParameters) (msgs []slack.Message, hasMore bool, cursor string, err error) {
+	err = s.do("conversations.replies", func() error {
+		msgs, hasMore, cursor, err = s.Backend.GetConversationReplies(params)
+		return err
+	})
+	return msgs, hasMore, cursor, err
+}
+
+func (s *Scheduler) OpenConversation(params *slack.OpenConversationParameters) (channel *slack.Channel, noOp bool, alreadyOpen bool, err error) {
+	err = s.do("conversations.open", func() error {
+		channel, noOp, alreadyOpen, err = s.Backend.OpenConversation(params)
+		return err
+	})
+	return channel, noOp, alreadyOpen, err
+}
+
+func (s *Scheduler) JoinConversation(channelID string) (channel *slack.Channel, warning string, warnings []string, err error) {
+	err = s.do("conversations.join", func() error {
+		channel, warning, warnings, err = s.Backend.JoinConversation(channelID)
+		return err
+	})
+	return channel, warning, warnings, err
+}
+
+func (s *Scheduler) LeaveConversation(channelID string) (notInChannel bool, err error) {
+	err = s.do("conversations.leave", func() error {
+		notInChannel, err = s.Backend.LeaveConversation(channelID)
+		return err
+	})
+	return notInChannel, err
+}
+
+func (s *Scheduler) CreateConversation(channelName string, isPrivate bool) (channel *slack.Channel, err error) {
+	err = s.do("conversations.create", func() error {
+		channel, err = s.Backend.CreateConversation(channelName, isPrivate)
+		return err
+	})
+	return channel, err
+}
+
+func (s *Scheduler) ArchiveConversation(channelID string) error {
+	return s.do("conversations.archive", func() error {
+		return s.Backend.ArchiveConversation(channelID)
+	})
+}
+
+func (s *Scheduler) SearchMessages(query string, params slack.SearchParameters) (messages *slack.SearchMessages, err error) {
+	err = s.do("search.messages", func() error {
+		messages, err = s.Backend.SearchMessages(query, params)
+		return err
+	})
+	return messages, err
+}
+
+func (s *Scheduler) PostMessage(channelID string, options ...slack.MsgOption) (channel string, timestamp string, err error) {
+	err = s.do("chat.postMessage", func() error {
+		channel, timestamp, err = s.Backend.PostMessage(channelID, options...)
+		return err
+	})
+	return channel, timestamp, err
+}
+
+func (s *Scheduler) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (channel string, ts string, text string, err error) {
+	err = s.do("chat.update", func() error {
+		channel, ts, text, err = s.Backend.UpdateMessage(channelID, timestamp, options...)
+		return err
+	})
+	return channel, ts, text, err
+}
+
+func (s *Scheduler) DeleteMessage(channel, messageTimestamp string) (respChannel string, timestamp string, err error) {
+	err = s.do("chat.delete", func() error {
+		respChannel, timestamp, err = s.Backend.DeleteMessage(channel, messageTimestamp)
+		return err
+	})
+	return respChannel, timestamp, err
+}
+
+func (s *Scheduler) AddReaction(name string, item slack.ItemRef) error {
+	return s.do("reactions.add", func() error {
+		return s.Backend.AddReaction(name, item)
+	})
+}
+
+func (s *Scheduler) RemoveReaction(name string, item slack.ItemRef) error {
+	return s.do("reactions.remove", func() error {
+		return s.Backend.RemoveReaction(name, item)
+	})
+}
+
+func (s *Scheduler) SetChannelReadMark(channelID, ts string) error {
+	return s.do("channels.mark", func() error {
+		return s.Backend.SetChannelReadMark(channelID, ts)
+	})
+}
+
+func (s *Scheduler) SetGroupReadMark(group, ts string) error {
+	return s.do("groups.mark", func() error {
+		return s.Backend.SetGroupReadMark(group, ts)
+	})
+}
+
+func (s *Scheduler) MarkIMChannel(channel, ts string) error {
+	return s.do("im.mark", func() error {
+		return s.Backend.MarkIMChannel(channel, ts)
+	})
+}
+
+func (s *Scheduler) GetNotificationPrefs() (prefs *NotificationPrefs, err error) {
+	err = s.do("users.prefs.get", func() error {
+		prefs, err = s.Backend.GetNotificationPrefs()
+		return err
+	})
+	return prefs, err
+}
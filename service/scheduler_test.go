@@ -0,0 +1,78 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerBucketPriority(t *testing.T) {
+	s := NewScheduler(NewFakeBackend())
+
+	now := time.Now()
+	b := newBucket(Tier{PerMinute: 600, Burst: 1}, now)
+
+	// The first request takes the only token, the others wait for the
+	// bucket to be refilled
+	s.mutex.Lock()
+	first := s.reserve(b, PriorityLow, now)
+	var low []<-chan struct{}
+	for i := 0; i < 3; i++ {
+		low = append(low, s.reserve(b, PriorityLow, now))
+	}
+	high := s.reserve(b, PriorityHigh, now)
+	s.mutex.Unlock()
+
+	select {
+	case <-first:
+	default:
+		t.Fatal("the first request had to wait for a token")
+	}
+
+	// The request with the high priority is handed the next token, before
+	// the requests that were queued earlier
+	order := make(chan string, 4)
+	go func() { <-high; order <- "high" }()
+	for _, ready := range low {
+		go func(ready <-chan struct{}) { <-ready; order <- "low" }(ready)
+	}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case got := <-order:
+			if i == 0 && got != "high" {
+				t.Fatalf("expected the high priority request first, got %s", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("the queued requests weren't handed a token")
+		}
+	}
+}
+
+func TestSchedulerPause(t *testing.T) {
+	s := NewScheduler(NewFakeBackend())
+
+	now := time.Now()
+	b := newBucket(Tier{PerMinute: 600, Burst: 5}, now)
+
+	// A paused bucket hands out no tokens until the pause has passed
+	s.mutex.Lock()
+	s.pause(b, now, 300*time.Millisecond)
+	ready := s.reserve(b, PriorityHigh, now)
+	s.mutex.Unlock()
+
+	select {
+	case <-ready:
+		t.Fatal("the request was sent while the method was paused")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	select {
+	case <-ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the request wasn't sent after the pause")
+	}
+
+	if since := time.Since(now); since < 300*time.Millisecond {
+		t.Errorf("the request was sent %s after the pause started", since)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"log"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
@@ -13,7 +13,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/OpenPeeDeeP/xdg"
+	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackutilsx"
 
 	"github.com/erroneousboat/slack-term/components"
 	"github.com/erroneousboat/slack-term/config"
@@ -21,22 +24,49 @@ import (
 
 type SlackService struct {
 	Config          *config.Config
-	Client          *slack.Client
-	RTM             *slack.RTM
+	Workspace       config.Workspace
+	Client          Backend
+	Background      Backend
+	Scheduler       *Scheduler
+	Cache           *Cache
+	IncomingEvents  chan slack.RTMEvent
 	Conversations   []slack.Channel
 	UserCache       map[string]string
+	Users           map[string]User
 	ThreadCache     map[string]string
 	CurrentUserID   string
 	CurrentUsername string
+	CurrentTeam     string
+
+	// cacheMutex guards the UserCache, the Users and the ThreadCache,
+	// because messages can be created concurrently, the Prefs and the
+	// UserGroups
+	cacheMutex sync.RWMutex
+
+	// Prefs are the notification preferences of the user, they're set
+	// when they have been loaded
+	Prefs *NotificationPrefs
+
+	// UserGroups are the user groups of the workspace, by their
+	// identifier, they're set when they have been loaded
+	UserGroups map[string]slack.UserGroup
 }
 
-// NewSlackService is the constructor for the SlackService and will initialize
-// the RTM and a Client
-func NewSlackService(config *config.Config) (*SlackService, error) {
+// NewSlackService is the constructor for the SlackService and will connect
+// to the workspace using the provided Backend
+func NewSlackService(config *config.Config, workspace config.Workspace, backend Backend) (*SlackService, error) {
+	// All the requests are sent through the scheduler, so that they stay
+	// within the rate limits of slack
+	scheduler := NewScheduler(backend)
+
 	svc := &SlackService{
 		Config:      config,
-		Client:      slack.New(config.SlackToken),
+		Workspace:   workspace,
+		Client:      scheduler,
+		Background:  scheduler.WithPriority(PriorityLow),
+		Scheduler:   scheduler,
 		UserCache:   make(map[string]string),
+		Users:       make(map[string]User),
 		ThreadCache: make(map[string]string),
 	}
 
@@ -48,32 +78,157 @@ func NewSlackService(config *config.Config) (*SlackService, error) {
 		return nil, errors.New("not able to authorize client, check your connection and if your slack-token is set correctly")
 	}
 	svc.CurrentUserID = authTest.UserID
+	svc.CurrentTeam = authTest.Team
 
-	// Create RTM
-	svc.RTM = svc.Client.NewRTM()
-	go svc.RTM.ManageConnection()
+	// The fake workspace is created anew every time, there is nothing
+	// to be cached
+	if _, ok := backend.(*FakeBackend); !ok && config.Cache {
+		svc.Cache = NewCache(
+			filepath.Join(xdg.CacheHome(), "slack-term", authTest.TeamID),
+		)
+	}
 
-	// Creation of user cache this speeds up
-	// the uncovering of usernames of messages
-	users, _ := svc.Client.GetUsers()
-	for _, user := range users {
-		// only add non-deleted users
-		if !user.Deleted {
-			svc.UserCache[user.ID] = user.Name
-		}
+	// Start receiving the incoming events
+	svc.IncomingEvents = svc.Client.Connect()
+
+	// Creation of user cache this speeds up the uncovering of usernames of
+	// messages. When the users were cached they're updated in the
+	// background.
+	if users, ok := svc.getCachedUsers(); ok {
+		svc.setUsers(users)
+		go svc.loadUsers()
+	} else {
+		svc.loadUsers()
 	}
 
 	// Get name of current user, and set presence to active
 	currentUser, err := svc.Client.GetUserInfo(svc.CurrentUserID)
 	if err != nil {
 		svc.CurrentUsername = "slack-term"
+	} else {
+		svc.CurrentUsername = currentUser.Name
 	}
-	svc.CurrentUsername = currentUser.Name
 	svc.SetUserAsActive()
 
 	return svc, nil
 }
 
+// User is a user of the workspace, it can be found by its name, its real
+// name and its display name
+type User struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	RealName    string `json:"real_name"`
+	DisplayName string `json:"display_name"`
+}
+
+// getCachedUsers returns the users from the Cache
+func (s *SlackService) getCachedUsers() ([]User, bool) {
+	if s.Cache == nil {
+		return nil, false
+	}
+	return s.Cache.GetUsers()
+}
+
+// loadUsers will add the users of the workspace to the Users and the
+// UserCache, and store them in the Cache
+func (s *SlackService) loadUsers() {
+	slackUsers, err := s.Client.GetUsers()
+	if err != nil {
+		return
+	}
+
+	users := make([]User, 0, len(slackUsers))
+	for _, user := range slackUsers {
+		// only add non-deleted users
+		if !user.Deleted {
+			users = append(users, User{
+				ID:          user.ID,
+				Name:        user.Name,
+				RealName:    user.RealName,
+				DisplayName: user.Profile.DisplayName,
+			})
+		}
+	}
+
+	s.setUsers(users)
+
+	if s.Cache != nil {
+		// The cache is best effort, without it everything is fetched
+		s.Cache.SetUsers(users)
+	}
+}
+
+// setUsers will set the users in the Users, and their names in the
+// UserCache
+func (s *SlackService) setUsers(users []User) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	for _, user := range users {
+		s.Users[user.ID] = user
+		s.UserCache[user.ID] = user.Name
+	}
+}
+
+// SearchUsers returns the users of which the name, the real name or the
+// display name matches the term, sorted by their name
+func (s *SlackService) SearchUsers(term string) []User {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	var users []User
+	for _, user := range s.Users {
+		for _, name := range []string{user.Name, user.RealName, user.DisplayName} {
+			if name != "" && fuzzy.MatchFold(term, name) {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	return users
+}
+
+// getUserName returns the name of a user or bot from the UserCache
+func (s *SlackService) getUserName(id string) (string, bool) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	name, ok := s.UserCache[id]
+	return name, ok
+}
+
+// setUserName sets the name of a user or bot in the UserCache
+func (s *SlackService) setUserName(id string, name string) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	s.UserCache[id] = name
+}
+
+// getThread returns the timestamp of a thread by its identifier from the
+// ThreadCache
+func (s *SlackService) getThread(id string) string {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	return s.ThreadCache[id]
+}
+
+// setThread sets the timestamp of a thread by its identifier in the
+// ThreadCache
+func (s *SlackService) setThread(id string, timestamp string) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	s.ThreadCache[id] = timestamp
+}
+
 func (s *SlackService) GetChannels() ([]components.ChannelItem, error) {
 	slackChans := make([]slack.Channel, 0)
 
@@ -120,6 +275,180 @@ func (s *SlackService) GetChannels() ([]components.ChannelItem, error) {
 		nextCur = cursor
 	}
 
+	if s.Cache != nil {
+		s.Cache.SetChannels(slackChans)
+	}
+
+	return s.createChannelItems(slackChans), nil
+}
+
+// GetCachedChannels returns the channels from the Cache, and whether they
+// were cached
+func (s *SlackService) GetCachedChannels() ([]components.ChannelItem, bool) {
+	if s.Cache == nil {
+		return nil, false
+	}
+
+	slackChans, ok := s.Cache.GetChannels()
+	if !ok {
+		return nil, false
+	}
+
+	return s.createChannelItems(slackChans), true
+}
+
+// OpenIM will open the direct message conversation with a user, slack
+// creates it when it doesn't exist yet. It is added to the Conversations.
+func (s *SlackService) OpenIM(userID string) (components.ChannelItem, error) {
+	chn, _, _, err := s.Client.OpenConversation(
+		&slack.OpenConversationParameters{
+			Users:    []string{userID},
+			ReturnIM: true,
+		},
+	)
+	if err != nil {
+		return components.ChannelItem{}, err
+	}
+
+	// Slack only returns the identifier when the conversation already
+	// existed
+	chn.IsIM = true
+	chn.User = userID
+
+	chanItem := s.createChannelItem(*chn)
+	chanItem.Name, _ = s.getUserName(userID)
+	chanItem.Type = components.ChannelTypeIM
+	chanItem.Presence = "away"
+
+	s.Conversations = append(s.Conversations, *chn)
+
+	return chanItem, nil
+}
+
+// PublicChannel is a public channel of the workspace, as it is shown in
+// the channel browser
+type PublicChannel struct {
+	ID       string
+	Name     string
+	Topic    string
+	Members  int
+	IsMember bool
+}
+
+// GetPublicChannels returns the public channels of the workspace that
+// aren't archived, including the ones the user isn't a member of, sorted by
+// their name
+func (s *SlackService) GetPublicChannels() ([]PublicChannel, error) {
+	var channels []PublicChannel
+
+	var cursor string
+	for {
+		slackChans, nextCur, err := s.Client.GetConversations(
+			&slack.GetConversationsParameters{
+				Cursor:          cursor,
+				ExcludeArchived: "true",
+				Limit:           1000,
+				Types:           []string{"public_channel"},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, chn := range slackChans {
+			channels = append(channels, PublicChannel{
+				ID:       chn.ID,
+				Name:     chn.Name,
+				Topic:    chn.Topic.Value,
+				Members:  chn.NumMembers,
+				IsMember: chn.IsMember,
+			})
+		}
+
+		if nextCur == "" {
+			break
+		}
+		cursor = nextCur
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return channels, nil
+}
+
+// JoinChannel will join a public channel, it is added to the Conversations
+func (s *SlackService) JoinChannel(channelID string) (components.ChannelItem, error) {
+	chn, _, _, err := s.Client.JoinConversation(channelID)
+	if err != nil {
+		return components.ChannelItem{}, err
+	}
+
+	return s.addConversation(*chn), nil
+}
+
+// CreateChannel will create a public channel, it is added to the
+// Conversations
+func (s *SlackService) CreateChannel(name string) (components.ChannelItem, error) {
+	chn, err := s.Client.CreateConversation(name, false)
+	if err != nil {
+		return components.ChannelItem{}, err
+	}
+
+	return s.addConversation(*chn), nil
+}
+
+// LeaveChannel will leave a channel, it is removed from the Conversations
+func (s *SlackService) LeaveChannel(channelID string) error {
+	if _, err := s.Client.LeaveConversation(channelID); err != nil {
+		return err
+	}
+
+	s.removeConversation(channelID)
+	return nil
+}
+
+// ArchiveChannel will archive a channel, it is removed from the
+// Conversations
+func (s *SlackService) ArchiveChannel(channelID string) error {
+	if err := s.Client.ArchiveConversation(channelID); err != nil {
+		return err
+	}
+
+	s.removeConversation(channelID)
+	return nil
+}
+
+// addConversation will add a channel that was joined or created to the
+// Conversations, and returns how it is shown
+func (s *SlackService) addConversation(chn slack.Channel) components.ChannelItem {
+	chanItem := s.createChannelItem(chn)
+	if chn.IsGroup || chn.IsPrivate {
+		chanItem.Type = components.ChannelTypeGroup
+	} else {
+		chanItem.Type = components.ChannelTypeChannel
+	}
+
+	s.Conversations = append(s.Conversations, chn)
+
+	return chanItem
+}
+
+// removeConversation will remove a channel that was left or archived from
+// the Conversations
+func (s *SlackService) removeConversation(channelID string) {
+	for i, chn := range s.Conversations {
+		if chn.ID == channelID {
+			s.Conversations = append(s.Conversations[:i], s.Conversations[i+1:]...)
+			return
+		}
+	}
+}
+
+// createChannelItems will create the channels that are shown, they are
+// sorted by type and name. The channels are set as the Conversations.
+func (s *SlackService) createChannelItems(slackChans []slack.Channel) []components.ChannelItem {
 	// We're creating tempChan, because we want to be able to
 	// sort the types of channels into buckets
 	type tempChan struct {
@@ -196,7 +525,7 @@ func (s *SlackService) GetChannels() ([]components.ChannelItem, error) {
 		if chn.IsIM {
 			// Check if user is deleted, we do this by checking the user id,
 			// and see if we have the user in the UserCache
-			name, ok := s.UserCache[chn.User]
+			name, ok := s.getUserName(chn.User)
 			if !ok {
 				continue
 			}
@@ -226,6 +555,7 @@ func (s *SlackService) GetChannels() ([]components.ChannelItem, error) {
 	sort.Ints(keys)
 
 	var chans []components.ChannelItem
+	var conversations []slack.Channel
 	for _, k := range keys {
 
 		bucket := buckets[k]
@@ -243,11 +573,12 @@ func (s *SlackService) GetChannels() ([]components.ChannelItem, error) {
 		// Add ChannelItem and SlackChannel to the SlackService struct
 		for _, tc := range tcArr {
 			chans = append(chans, tc.channelItem)
-			s.Conversations = append(s.Conversations, tc.slackChannel)
+			conversations = append(conversations, tc.slackChannel)
 		}
 	}
+	s.Conversations = conversations
 
-	return chans, nil
+	return chans
 }
 
 // GetUserPresence will get the presence of a specific user
@@ -260,6 +591,154 @@ func (s *SlackService) GetUserPresence(userID string) (string, error) {
 	return presence.Presence, nil
 }
 
+// Unread is the state of the messages of a channel that the user hasn't
+// read
+type Unread struct {
+	Unread bool
+	Count  int       // the amount of unread messages that slack displays
+	Latest time.Time // the time of the latest message
+}
+
+// LoadUserGroups will get the user groups of the workspace, the mentions of
+// the user groups the user is a member of are mentions of the user
+func (s *SlackService) LoadUserGroups() error {
+	groups, err := s.Client.GetUserGroups(
+		slack.GetUserGroupsOptionIncludeUsers(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	userGroups := make(map[string]slack.UserGroup)
+	for _, group := range groups {
+		userGroups[group.ID] = group
+	}
+
+	s.cacheMutex.Lock()
+	s.UserGroups = userGroups
+	s.cacheMutex.Unlock()
+
+	return nil
+}
+
+// IsUserGroupMember returns whether the user is a member of the user group
+func (s *SlackService) IsUserGroupMember(groupID string) bool {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	for _, user := range s.UserGroups[groupID].Users {
+		if user == s.CurrentUserID {
+			return true
+		}
+	}
+	return false
+}
+
+// getUserGroupHandle returns the handle of the user group, and whether the
+// user group is known
+func (s *SlackService) getUserGroupHandle(groupID string) (string, bool) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	group, ok := s.UserGroups[groupID]
+	return group.Handle, ok
+}
+
+// GetHighlights returns the expressions that are highlighted in the
+// messages: the highlight rules of the config, and the mentions of the
+// user, of everyone in a channel and of the user groups the user is a
+// member of
+func (s *SlackService) GetHighlights() []*regexp.Regexp {
+	names := []string{"here", "channel", "everyone"}
+	if s.CurrentUsername != "" {
+		names = append(names, regexp.QuoteMeta(s.CurrentUsername))
+	}
+
+	s.cacheMutex.RLock()
+	for _, group := range s.UserGroups {
+		for _, user := range group.Users {
+			if user == s.CurrentUserID && group.Handle != "" {
+				names = append(names, regexp.QuoteMeta(group.Handle))
+				break
+			}
+		}
+	}
+	s.cacheMutex.RUnlock()
+
+	mentions := regexp.MustCompile(`\B@(?:` + strings.Join(names, "|") + `)\b`)
+
+	return append([]*regexp.Regexp{mentions}, s.Config.GetHighlights()...)
+}
+
+// notificationLevels are the notification levels of slack, and the levels
+// of the config they correspond to
+var notificationLevels = map[string]string{
+	"everything": config.LevelAll,
+	"mentions":   config.LevelMentions,
+	"nothing":    config.LevelNothing,
+}
+
+// LoadNotificationPrefs will get the muted channels and the notification
+// levels of the channels from the preferences of the user
+func (s *SlackService) LoadNotificationPrefs() error {
+	prefs, err := s.Client.GetNotificationPrefs()
+	if err != nil {
+		return err
+	}
+
+	s.cacheMutex.Lock()
+	s.Prefs = prefs
+	s.cacheMutex.Unlock()
+
+	return nil
+}
+
+// GetNotificationPrefs returns whether the channel is muted in slack, and
+// its notification level, which is empty when it doesn't have one
+func (s *SlackService) GetNotificationPrefs(channelID string) (bool, string) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	if s.Prefs == nil {
+		return false, ""
+	}
+
+	muted := false
+	for _, id := range s.Prefs.MutedChannels {
+		if id == channelID {
+			muted = true
+			break
+		}
+	}
+
+	return muted, notificationLevels[s.Prefs.Levels[channelID]]
+}
+
+// GetUnread returns whether the channel has messages that the user hasn't
+// read, by comparing the timestamp of the latest message with the one of
+// the last read message.
+//
+// https://api.slack.com/methods/conversations.info
+func (s *SlackService) GetUnread(channelID string) (Unread, error) {
+	chn, err := s.Client.GetConversationInfo(channelID, false)
+	if err != nil {
+		return Unread{}, err
+	}
+
+	if chn.Latest == nil {
+		return Unread{
+			Unread: chn.UnreadCountDisplay > 0,
+			Count:  chn.UnreadCountDisplay,
+		}, nil
+	}
+
+	return Unread{
+		Unread: chn.Latest.Timestamp > chn.LastRead,
+		Count:  chn.UnreadCountDisplay,
+		Latest: parseTimestamp(chn.Latest.Timestamp),
+	}, nil
+}
+
 // Set current user presence to active
 func (s *SlackService) SetUserAsActive() {
 	s.Client.SetUserPresence("auto")
@@ -314,14 +793,16 @@ func (s *SlackService) SendMessage(channelID string, message string) error {
 
 // SendReply will send a message to a particular thread, specifying the
 // ThreadTimestamp will make it reply to that specific thread. (see:
-// https://api.slack.com/docs/message-threading, 'Posting replies')
-func (s *SlackService) SendReply(channelID string, threadID string, message string) error {
+// https://api.slack.com/docs/message-threading, 'Posting replies'). When
+// broadcast is set the reply is also sent to the channel.
+func (s *SlackService) SendReply(channelID string, threadID string, message string, broadcast bool) error {
 	// https://godoc.org/github.com/nlopes/slack#PostMessageParameters
 	postParams := slack.MsgOptionPostMessageParameters(slack.PostMessageParameters{
 		AsUser:          true,
 		Username:        s.CurrentUsername,
 		LinkNames:       1,
 		ThreadTimestamp: threadID,
+		ReplyBroadcast:  broadcast,
 	})
 
 	text := slack.MsgOptionText(message, true)
@@ -335,6 +816,64 @@ func (s *SlackService) SendReply(channelID string, threadID string, message stri
 	return nil
 }
 
+// UpdateMessage will replace the text of a message, identified by its
+// timestamp, in a particular channel
+func (s *SlackService) UpdateMessage(channelID string, messageID string, message string) error {
+	// https://godoc.org/github.com/nlopes/slack#PostMessageParameters
+	postParams := slack.MsgOptionPostMessageParameters(slack.PostMessageParameters{
+		AsUser:    true,
+		LinkNames: 1,
+	})
+
+	// The message is edited with the formatting of slack, the links and
+	// mentions in it aren't escaped
+	text := slack.MsgOptionText(escapeText(message), false)
+
+	// https://godoc.org/github.com/nlopes/slack#Client.UpdateMessage
+	_, _, _, err := s.Client.UpdateMessage(channelID, messageID, text, postParams)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMessage will delete a message, identified by its timestamp, in a
+// particular channel
+func (s *SlackService) DeleteMessage(channelID string, messageID string) error {
+	// https://godoc.org/github.com/nlopes/slack#Client.DeleteMessage
+	_, _, err := s.Client.DeleteMessage(channelID, messageID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddReaction will add an emoji reaction, by its name, to a message in a
+// particular channel
+func (s *SlackService) AddReaction(channelID string, messageID string, name string) error {
+	// https://godoc.org/github.com/nlopes/slack#Client.AddReaction
+	err := s.Client.AddReaction(name, slack.NewRefToMessage(channelID, messageID))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveReaction will remove an emoji reaction, by its name, from a
+// message in a particular channel
+func (s *SlackService) RemoveReaction(channelID string, messageID string, name string) error {
+	// https://godoc.org/github.com/nlopes/slack#Client.RemoveReaction
+	err := s.Client.RemoveReaction(name, slack.NewRefToMessage(channelID, messageID))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // SendCommand will send a specific command to slack. First we check
 // wether we are dealing with a command, and if it is one of the supported
 // ones.
@@ -366,10 +905,10 @@ func (s *SlackService) SendCommand(channelID string, message string) (bool, erro
 			return false, errors.New("'/thread' command malformed")
 		}
 
-		threadID := s.ThreadCache[subMatch[2]]
+		threadID := s.getThread(subMatch[2])
 		msg := subMatch[3]
 
-		err := s.SendReply(channelID, threadID, msg)
+		err := s.SendReply(channelID, threadID, msg, false)
 		if err != nil {
 			return false, err
 		}
@@ -387,7 +926,7 @@ func (s *SlackService) SendCommand(channelID string, message string) (bool, erro
 		text := subMatch[2]
 
 		msgOption := slack.UnsafeMsgOptionEndpoint(
-			fmt.Sprintf("%s%s", slack.APIURL, "chat.command"),
+			apiMethodURL(s.Workspace.APIURL, "chat.command"),
 			func(urlValues url.Values) {
 				urlValues.Add("command", cmd)
 				urlValues.Add("text", text)
@@ -401,8 +940,6 @@ func (s *SlackService) SendCommand(channelID string, message string) (bool, erro
 
 		return true, nil
 	}
-
-	return false, nil
 }
 
 // GetMessages will get messages for a channel, group or im channel delimited
@@ -417,15 +954,95 @@ func (s *SlackService) GetMessages(channelID string, count int) ([]components.Me
 		Inclusive: false,
 	}
 
-	history, err := s.Client.GetConversationHistory(&historyParams)
+	msgs, threads, _, err := s.getMessages(&historyParams)
+	return msgs, threads, err
+}
+
+// GetCachedMessages will get the messages of a channel from the Cache,
+// delimited by a count. It will return the messages, the thread
+// identifiers (as ChannelItem), and whether they were cached.
+func (s *SlackService) GetCachedMessages(channelID string, count int) ([]components.Message, []components.ChannelItem, bool) {
+	if s.Cache == nil {
+		return nil, nil, false
+	}
+
+	history, ok := s.Cache.GetMessages(channelID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if len(history) > count {
+		history = history[:count]
+	}
+
+	msgs, threads := s.createMessages(history, channelID)
+	return msgs, threads, true
+}
+
+// GetOlderMessages will get the messages of a channel that were sent before
+// the message with the timestamp latest, delimited by a count. Besides the
+// messages and thread identifiers, it returns whether there are even older
+// messages to be fetched.
+func (s *SlackService) GetOlderMessages(channelID string, latest string, count int) ([]components.Message, []components.ChannelItem, bool, error) {
+
+	// https://godoc.org/github.com/nlopes/slack#GetConversationHistoryParameters
+	historyParams := slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Limit:     count,
+		Latest:    latest,
+		Inclusive: false,
+	}
+
+	return s.getMessages(&historyParams)
+}
+
+// GetNewerMessages will get the messages of a channel that were sent after
+// the message with the timestamp oldest, delimited by a count. Besides the
+// messages and thread identifiers, it returns whether there are more newer
+// messages than the count.
+func (s *SlackService) GetNewerMessages(channelID string, oldest string, count int) ([]components.Message, []components.ChannelItem, bool, error) {
+
+	// https://godoc.org/github.com/nlopes/slack#GetConversationHistoryParameters
+	historyParams := slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Limit:     count,
+		Oldest:    oldest,
+		Inclusive: false,
+	}
+
+	return s.getMessages(&historyParams)
+}
+
+// getMessages will get a page of the history of a channel and construct the
+// messages, with the newest in the last place
+func (s *SlackService) getMessages(historyParams *slack.GetConversationHistoryParameters) ([]components.Message, []components.ChannelItem, bool, error) {
+	channelID := historyParams.ChannelID
+
+	history, err := s.Client.GetConversationHistory(historyParams)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	// Construct the messages
+	// The most recent messages replace the ones that were cached, the
+	// other pages are added to them
+	if s.Cache != nil {
+		if historyParams.Latest == "" && historyParams.Oldest == "" {
+			s.Cache.SetMessages(channelID, history.Messages)
+		} else {
+			s.Cache.AddMessages(channelID, history.Messages)
+		}
+	}
+
+	messages, threads := s.createMessages(history.Messages, channelID)
+	return messages, threads, history.HasMore, nil
+}
+
+// createMessages will construct the messages of a page of the history of a
+// channel, with the newest in the last place, and the thread identifiers
+func (s *SlackService) createMessages(history []slack.Message, channelID string) ([]components.Message, []components.ChannelItem) {
 	var messages []components.Message
 	var threads []components.ChannelItem
-	for _, message := range history.Messages {
+	for _, message := range history {
 		msg := s.CreateMessage(message, channelID)
 		messages = append(messages, msg)
 
@@ -449,38 +1066,121 @@ func (s *SlackService) GetMessages(channelID string, count int) ([]components.Me
 		messagesReversed = append(messagesReversed, messages[i])
 	}
 
-	return messagesReversed, threads, nil
+	return messagesReversed, threads
 }
 
-// CreateMessageByID will construct an array of components.Message with only
-// 1 message, using the message ID (Timestamp).
+// SearchResult is a message that was found by a search, the ThreadID is
+// set when the message is a thread reply. When a user was found the UserID
+// is set instead, and the Message shows the names of the user. The Channel
+// is set for the channels of the channel browser.
+type SearchResult struct {
+	ChannelID string
+	ThreadID  string
+	UserID    string
+	Channel   *PublicChannel
+	Message   components.Message
+}
+
+// SearchMessages will search the messages of the workspace, the newest
+// are returned first delimited by a count.
 //
-// For the choice of history parameters see:
-// https://api.slack.com/messaging/retrieving
-func (s *SlackService) GetMessageByID(messageID string, channelID string) ([]components.Message, error) {
+// https://api.slack.com/methods/search.messages
+func (s *SlackService) SearchMessages(query string, count int) ([]SearchResult, error) {
+	params := slack.NewSearchParameters()
+	params.Sort = "timestamp"
+	params.Count = count
 
-	var msgs []components.Message
+	found, err := s.Client.SearchMessages(query, params)
+	if err != nil {
+		return nil, err
+	}
 
-	// https://godoc.org/github.com/nlopes/slack#GetConversationHistoryParameters
-	historyParams := slack.GetConversationHistoryParameters{
-		ChannelID: channelID,
-		Limit:     1,
-		Inclusive: true,
-		Latest:    messageID,
+	var results []SearchResult
+	for _, match := range found.Matches {
+		message := slack.Message{}
+		message.User = match.User
+		message.Username = match.Username
+		message.Text = match.Text
+		message.Timestamp = match.Timestamp
+		message.Attachments = match.Attachments
+
+		// The thread of a reply is only part of the permalink
+		var threadID string
+		if permalink, err := url.Parse(match.Permalink); err == nil {
+			threadID = permalink.Query().Get("thread_ts")
+		}
+
+		results = append(results, SearchResult{
+			ChannelID: match.Channel.ID,
+			ThreadID:  threadID,
+			Message:   s.CreateMessage(message, match.Channel.ID),
+		})
+	}
+
+	return results, nil
+}
+
+// SearchCachedMessages will search the messages in the Cache, the newest
+// are returned first delimited by a count
+func (s *SlackService) SearchCachedMessages(query string, count int) []SearchResult {
+	if s.Cache == nil {
+		return nil
 	}
 
-	history, err := s.Client.GetConversationHistory(&historyParams)
+	var results []SearchResult
+	for _, match := range s.Cache.Search(query, count) {
+		var threadID string
+		if match.Message.ThreadTimestamp != match.Message.Timestamp {
+			threadID = match.Message.ThreadTimestamp
+		}
+
+		results = append(results, SearchResult{
+			ChannelID: match.ChannelID,
+			ThreadID:  threadID,
+			Message:   s.CreateMessage(match.Message, match.ChannelID),
+		})
+	}
+
+	return results
+}
+
+// GetThread will get a page of the replies of a thread, the page starts at
+// the cursor and is delimited by a count. It will return the parent
+// message, the replies, and the cursor of the next page which is empty when
+// there are no more replies.
+//
+// https://api.slack.com/messaging/retrieving#finding_threads
+func (s *SlackService) GetThread(channelID string, threadID string, cursor string, count int) (components.Message, []components.Message, string, error) {
+
+	// https://godoc.org/github.com/nlopes/slack#GetConversationRepliesParameters
+	msgs, _, nextCursor, err := s.Client.GetConversationReplies(
+		&slack.GetConversationRepliesParameters{
+			ChannelID: channelID,
+			Timestamp: threadID,
+			Cursor:    cursor,
+			Limit:     count,
+		},
+	)
 	if err != nil {
-		return msgs, err
+		return components.Message{}, nil, "", err
 	}
 
-	// We break because we're only asking for 1 message
-	for _, message := range history.Messages {
-		msgs = append(msgs, s.CreateMessage(message, channelID))
-		break
+	var parent components.Message
+	var replies []components.Message
+	for _, msg := range msgs {
+		// Every page starts with the parent message
+		if msg.Timestamp == threadID {
+			parent = s.CreateMessage(msg, channelID)
+			continue
+		}
+
+		reply := s.CreateMessage(msg, channelID)
+		reply.Thread = "  "
+
+		replies = append(replies, reply)
 	}
 
-	return msgs, nil
+	return parent, replies, nextCursor, nil
 }
 
 // CreateMessage will create a string formatted message that can be rendered
@@ -491,24 +1191,24 @@ func (s *SlackService) CreateMessage(message slack.Message, channelID string) co
 	var name string
 
 	// Get username from cache
-	name, ok := s.UserCache[message.User]
+	name, ok := s.getUserName(message.User)
 
 	// Name not in cache
 	if !ok {
 		if message.BotID != "" {
-			name, ok = s.UserCache[message.BotID]
+			name, ok = s.getUserName(message.BotID)
 			if !ok {
 				if message.Username != "" {
 					name = message.Username
-					s.UserCache[message.BotID] = message.Username
+					s.setUserName(message.BotID, message.Username)
 				} else {
 					bot, err := s.Client.GetBotInfo(message.BotID)
 					if err != nil {
 						name = "unkown"
-						s.UserCache[message.BotID] = name
+						s.setUserName(message.BotID, name)
 					} else {
 						name = bot.Name
-						s.UserCache[message.BotID] = bot.Name
+						s.setUserName(message.BotID, bot.Name)
 					}
 				}
 			}
@@ -517,10 +1217,10 @@ func (s *SlackService) CreateMessage(message slack.Message, channelID string) co
 			user, err := s.Client.GetUserInfo(message.User)
 			if err != nil {
 				name = "unknown"
-				s.UserCache[message.User] = name
+				s.setUserName(message.User, name)
 			} else {
 				name = user.Name
-				s.UserCache[message.User] = user.Name
+				s.setUserName(message.User, user.Name)
 			}
 		}
 	}
@@ -529,20 +1229,17 @@ func (s *SlackService) CreateMessage(message slack.Message, channelID string) co
 		name = "unknown"
 	}
 
-	// Parse time
-	floatTime, err := strconv.ParseFloat(message.Timestamp, 64)
-	if err != nil {
-		floatTime = 0.0
-	}
-	intTime := int64(floatTime)
-
 	// Format message
 	msg := components.Message{
 		ID:          message.Timestamp,
 		Messages:    make(map[string]components.Message),
-		Time:        time.Unix(intTime, 0),
+		Time:        parseTimestamp(message.Timestamp),
+		ThreadID:    message.ThreadTimestamp,
+		UserID:      message.User,
 		Name:        name,
 		Content:     parseMessage(s, message.Text),
+		Text:        message.Text,
+		Edited:      message.Edited != nil,
 		StyleTime:   s.Config.Theme.Message.Time,
 		StyleThread: s.Config.Theme.Message.Thread,
 		StyleName:   s.Config.Theme.Message.Name,
@@ -550,6 +1247,13 @@ func (s *SlackService) CreateMessage(message slack.Message, channelID string) co
 		FormatTime:  s.Config.Theme.Message.TimeFormat,
 	}
 
+	for _, r := range message.Reactions {
+		reaction := s.CreateReaction(r.Name)
+		reaction.Count = r.Count
+		reaction.Users = r.Users
+		msg.Reactions = append(msg.Reactions, reaction)
+	}
+
 	// When there are attachments, add them to Messages
 	//
 	// NOTE: attachments don't have an id or a timestamp that we can
@@ -584,34 +1288,97 @@ func (s *SlackService) CreateMessage(message slack.Message, channelID string) co
 		// Set the thread identifier for thread cache
 		f, _ := strconv.ParseFloat(message.ThreadTimestamp, 64)
 		threadID := hashID(int(f))
-		s.ThreadCache[threadID] = message.ThreadTimestamp
+		s.setThread(threadID, message.ThreadTimestamp)
 
 		// Set thread prefix for message
 		msg.Thread = fmt.Sprintf("%s ", threadID)
 
-		// Create the message replies from the thread
-		replies := s.CreateMessageFromReplies(message.ThreadTimestamp, channelID)
-		for _, reply := range replies {
-			msg.Messages[reply.ID] = reply
+		// The replies themselves are fetched by GetReplies, until then
+		// the amount of replies is shown. The vendored slack library
+		// doesn't decode latest_reply, so we use the last of the replies
+		// when they're present.
+		msg.ReplyCount = message.ReplyCount
+		if len(message.Replies) > 0 {
+			msg.LatestReply = parseTimestamp(message.Replies[len(message.Replies)-1].Timestamp)
 		}
 	}
 
 	return msg
 }
 
+// Replies is the result of fetching the replies of a thread by GetReplies
+type Replies struct {
+	ChannelID string
+	ThreadID  string
+	Messages  []components.Message
+	Err       error
+}
+
+// maxReplyWorkers is the amount of threads of which the replies are
+// fetched at the same time
+const maxReplyWorkers = 4
+
+// GetReplies will fetch the replies of the threads concurrently, with at
+// most maxReplyWorkers requests at the same time. The replies of every
+// thread are delivered on the returned channel, which is closed when all
+// threads are done. When done is closed no new requests are made, and the
+// returned channel is closed once the requests in flight have finished.
+func (s *SlackService) GetReplies(channelID string, threadIDs []string, done <-chan struct{}) <-chan Replies {
+	jobs := make(chan string)
+	results := make(chan Replies)
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxReplyWorkers && i < len(threadIDs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for threadID := range jobs {
+				msgs, err := s.CreateMessageFromReplies(threadID, channelID)
+
+				select {
+				case results <- Replies{
+					ChannelID: channelID,
+					ThreadID:  threadID,
+					Messages:  msgs,
+					Err:       err,
+				}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+	Jobs:
+		for _, threadID := range threadIDs {
+			select {
+			case jobs <- threadID:
+			case <-done:
+				break Jobs
+			}
+		}
+		close(jobs)
+
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
 // CreateMessageFromReplies will create components.Message struct from
-// the conversation replies from slack.
+// the conversation replies from slack. The replies are loaded in the
+// background, after the requests the user is waiting on.
 //
 // Useful documentation:
 //
 // https://api.slack.com/docs/message-threading
-// https://api.slack.com/methods/conversations.replies
-// https://godoc.org/github.com/nlopes/slack#Client.GetConversationReplies
-// https://godoc.org/github.com/nlopes/slack#GetConversationRepliesParameters
-func (s *SlackService) CreateMessageFromReplies(messageID string, channelID string) []components.Message {
+func (s *SlackService) CreateMessageFromReplies(messageID string, channelID string) ([]components.Message, error) {
 	msgs := make([]slack.Message, 0)
 
-	initReplies, _, initCur, err := s.Client.GetConversationReplies(
+	initReplies, _, initCur, err := s.Background.GetConversationReplies(
 		&slack.GetConversationRepliesParameters{
 			ChannelID: channelID,
 			Timestamp: messageID,
@@ -619,22 +1386,21 @@ func (s *SlackService) CreateMessageFromReplies(messageID string, channelID stri
 		},
 	)
 	if err != nil {
-		log.Fatal(err) // FIXME
+		return nil, err
 	}
 
 	msgs = append(msgs, initReplies...)
 
 	nextCur := initCur
 	for nextCur != "" {
-		conversationReplies, _, cursor, err := s.Client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+		conversationReplies, _, cursor, err := s.Background.GetConversationReplies(&slack.GetConversationRepliesParameters{
 			ChannelID: channelID,
 			Timestamp: messageID,
 			Cursor:    nextCur,
 			Limit:     200,
 		})
-
 		if err != nil {
-			log.Fatal(err) // FIXME
+			return nil, err
 		}
 
 		msgs = append(msgs, conversationReplies...)
@@ -661,7 +1427,7 @@ func (s *SlackService) CreateMessageFromReplies(messageID string, channelID stri
 		replies = append(replies, msg)
 	}
 
-	return replies
+	return replies, nil
 }
 
 // CreateMessageFromAttachments will construct an array of strings from the
@@ -756,16 +1522,44 @@ func (s *SlackService) CreateMessageFromFiles(files []slack.File) []components.M
 func (s *SlackService) CreateMessageFromMessageEvent(message *slack.MessageEvent, channelID string) (components.Message, error) {
 	msg := slack.Message{Msg: message.Msg}
 
+	var edited bool
 	switch message.SubType {
 	case "message_changed":
-		// Append (edited) when an edited message is received
 		msg = slack.Message{Msg: *message.SubMessage}
-		msg.Text = fmt.Sprintf("%s (edited)", msg.Text)
+		edited = true
 	case "message_replied":
 		return components.Message{}, errors.New("ignoring reply events")
 	}
 
-	return s.CreateMessage(msg, channelID), nil
+	m := s.CreateMessage(msg, channelID)
+
+	// Mark as (edited) when an edited message is received
+	if edited {
+		m.Edited = true
+	}
+
+	return m, nil
+}
+
+// CreateReaction will create a reaction that can be rendered underneath a
+// message in the Chat pane. When emoji is enabled the reaction is shown
+// as its unicode equivalent, skin tone modifiers are dropped.
+//
+// :+1::skin-tone-2: 1
+func (s *SlackService) CreateReaction(name string) components.Reaction {
+	icon := fmt.Sprintf(":%s:", name)
+
+	if s.Config.Emoji {
+		base := strings.Split(name, "::")[0]
+		if code, ok := config.EmojiCodemap[fmt.Sprintf(":%s:", base)]; ok {
+			icon = code
+		}
+	}
+
+	return components.Reaction{
+		Name: name,
+		Icon: icon,
+	}
 }
 
 // parseMessage will parse a message string and find and replace:
@@ -779,11 +1573,44 @@ func parseMessage(s *SlackService, msg string) string {
 
 	msg = parseMentions(s, msg)
 
+	msg = parseSpecialMentions(s, msg)
+
 	msg = html.UnescapeString(msg)
 
 	return msg
 }
 
+// slackMarkup matches the links, the mentions and the references to
+// channels in the text of a message, as slack formats them
+var slackMarkup = regexp.MustCompile(`<(?:[@#!]|https?://|mailto:)[^<>]*>`)
+
+// unescapeText is the reverse of the escaping of the text of a message by
+// slack, only &, < and > are escaped
+var unescapeText = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+// EditableText returns the text of the message as slack formats it, with
+// the escaping undone, so that it can be edited. The links and mentions
+// are kept as they are, they're left alone by escapeText.
+func EditableText(text string) string {
+	return unescapeText.Replace(text)
+}
+
+// escapeText will escape the &, < and > of the text of a message, except
+// for the < and > of the links, the mentions and the references to channels
+func escapeText(text string) string {
+	var escaped strings.Builder
+
+	last := 0
+	for _, loc := range slackMarkup.FindAllStringIndex(text, -1) {
+		escaped.WriteString(slackutilsx.EscapeMessage(text[last:loc[0]]))
+		escaped.WriteString(strings.Replace(text[loc[0]:loc[1]], "&", "&amp;", -1))
+		last = loc[1]
+	}
+	escaped.WriteString(slackutilsx.EscapeMessage(text[last:]))
+
+	return escaped.String()
+}
+
 // parseMentions will try to find mention placeholders in the message
 // string and replace them with the correct username with and @ symbol
 //
@@ -808,15 +1635,15 @@ func parseMentions(s *SlackService, msg string) string {
 				userID = rs[1]
 			}
 
-			name, ok := s.UserCache[userID]
+			name, ok := s.getUserName(userID)
 			if !ok {
 				user, err := s.Client.GetUserInfo(userID)
 				if err != nil {
 					name = "unknown"
-					s.UserCache[userID] = name
+					s.setUserName(userID, name)
 				} else {
 					name = user.Name
-					s.UserCache[userID] = user.Name
+					s.setUserName(userID, user.Name)
 				}
 			}
 
@@ -829,6 +1656,40 @@ func parseMentions(s *SlackService, msg string) string {
 	)
 }
 
+// parseSpecialMentions will replace the mentions of everyone in a channel,
+// and of the user groups, with their name with an @ symbol
+//
+// Special mentions have the following format:
+//
+//	<!here>
+//	<!channel|channel>
+//	<!subteam^S12345|@team>
+//	<!subteam^S12345>
+func parseSpecialMentions(s *SlackService, msg string) string {
+	r := regexp.MustCompile(`\<!(here|channel|everyone|subteam\^(\w+))(\|[^>]*)?\>`)
+
+	return r.ReplaceAllStringFunc(
+		msg, func(str string) string {
+			rs := r.FindStringSubmatch(str)
+
+			// Everyone in the channel
+			if rs[2] == "" {
+				return "@" + rs[1]
+			}
+
+			// The user group, by its label or by its handle
+			if label := strings.TrimPrefix(rs[3], "|"); label != "" {
+				return label
+			}
+			if handle, ok := s.getUserGroupHandle(rs[2]); ok {
+				return "@" + handle
+			}
+
+			return str
+		},
+	)
+}
+
 // parseEmoji will try to find emoji placeholders in the message
 // string and replace them with the correct unicode equivalent
 func parseEmoji(msg string) string {
@@ -846,15 +1707,34 @@ func parseEmoji(msg string) string {
 }
 
 func (s *SlackService) createChannelItem(chn slack.Channel) components.ChannelItem {
-	return components.ChannelItem{
+	item := components.ChannelItem{
 		ID:          chn.ID,
 		Name:        chn.Name,
 		Topic:       chn.Topic.Value,
 		UserID:      chn.User,
+		UnreadCount: chn.UnreadCountDisplay,
 		StylePrefix: s.Config.Theme.Channel.Prefix,
 		StyleIcon:   s.Config.Theme.Channel.Icon,
 		StyleText:   s.Config.Theme.Channel.Text,
 	}
+
+	if chn.Latest != nil {
+		item.Latest = parseTimestamp(chn.Latest.Timestamp)
+	}
+
+	item.Muted, item.Level = s.GetNotificationPrefs(chn.ID)
+
+	return item
+}
+
+// parseTimestamp will convert the timestamp of a message to a time
+func parseTimestamp(timestamp string) time.Time {
+	floatTime, err := strconv.ParseFloat(timestamp, 64)
+	if err != nil {
+		floatTime = 0.0
+	}
+
+	return time.Unix(int64(floatTime), 0)
 }
 
 func hashID(input int) string {
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// socketModeTimeout is the time after which the connection is
+	// considered dead, when nothing was received from slack
+	socketModeTimeout = 2 * time.Minute
+
+	// socketModeMaxBackoff is the maximum time between two attempts to
+	// connect
+	socketModeMaxBackoff = 5 * time.Minute
+)
+
+// errRefresh is returned when slack asks us to reconnect, which it does
+// periodically and before a server is taken down
+var errRefresh = errors.New("slack requested a reconnect")
+
+// SocketMode receives the events of a slack app over a websocket, this is
+// the successor of the RTM. It delivers the same events on IncomingEvents
+// as the RTM does, including the ones about the state of the connection.
+//
+// https://api.slack.com/apis/connections/socket
+type SocketMode struct {
+	AppToken       string
+	APIURL         string
+	IncomingEvents chan slack.RTMEvent
+}
+
+// socketModeEnvelope is the definition of a message that is received over
+// the websocket, the events are wrapped in its payload
+type socketModeEnvelope struct {
+	Type       string `json:"type"`
+	EnvelopeID string `json:"envelope_id"`
+	Payload    struct {
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// NewSocketMode is the constructor for SocketMode, the appToken is an
+// app-level token with the connections:write scope
+func NewSocketMode(appToken string, apiURL string) *SocketMode {
+	return &SocketMode{
+		AppToken:       appToken,
+		APIURL:         apiURL,
+		IncomingEvents: make(chan slack.RTMEvent, 50),
+	}
+}
+
+// ManageConnection will connect to slack and reconnect whenever the
+// connection is lost, it should be run in its own goroutine
+func (s *SocketMode) ManageConnection() {
+	for connectionCount := 1; ; connectionCount++ {
+		conn := s.connect(connectionCount)
+
+		s.IncomingEvents <- slack.RTMEvent{
+			Type: "connected",
+			Data: &slack.ConnectedEvent{ConnectionCount: connectionCount},
+		}
+
+		err := s.handleEvents(conn)
+		conn.Close()
+
+		// A requested reconnect isn't an outage, so it's marked as
+		// intentional
+		s.IncomingEvents <- slack.RTMEvent{
+			Type: "disconnected",
+			Data: &slack.DisconnectedEvent{
+				Intentional: err == errRefresh,
+				Cause:       err,
+			},
+		}
+	}
+}
+
+// connect will open a websocket connection, it keeps trying with an
+// exponential backoff until it succeeds
+func (s *SocketMode) connect(connectionCount int) *websocket.Conn {
+	backoff := time.Second
+
+	for attempt := 1; ; attempt++ {
+		s.IncomingEvents <- slack.RTMEvent{
+			Type: "connecting",
+			Data: &slack.ConnectingEvent{
+				Attempt:         attempt,
+				ConnectionCount: connectionCount,
+			},
+		}
+
+		conn, err := s.dial()
+		if err == nil {
+			return conn
+		}
+
+		wait := backoff
+		if retryAfter := RetryAfter(err); retryAfter > wait {
+			wait = retryAfter
+		}
+
+		s.IncomingEvents <- slack.RTMEvent{
+			Type: "connection_error",
+			Data: &slack.ConnectionErrorEvent{
+				Attempt:  attempt,
+				Backoff:  wait,
+				ErrorObj: err,
+			},
+		}
+
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > socketModeMaxBackoff {
+			backoff = socketModeMaxBackoff
+		}
+	}
+}
+
+// dial will request the url of the websocket and connect to it
+//
+// https://api.slack.com/methods/apps.connections.open
+func (s *SocketMode) dial() (*websocket.Conn, error) {
+	req, err := http.NewRequest(
+		http.MethodPost, apiMethodURL(s.APIURL, "apps.connections.open"), nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.AppToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, &slack.RateLimitedError{
+			RetryAfter: time.Duration(retryAfter) * time.Second,
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("apps.connections.open: %s", resp.Status)
+	}
+
+	var open struct {
+		slack.SlackResponse
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&open); err != nil {
+		return nil, err
+	}
+
+	if !open.Ok {
+		return nil, fmt.Errorf("apps.connections.open: %s", open.Error)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(open.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// handleEvents will read the envelopes from the connection, acknowledge
+// them and deliver their events, until the connection is lost or slack
+// asks us to reconnect
+func (s *SocketMode) handleEvents(conn *websocket.Conn) error {
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(socketModeTimeout))
+		return conn.WriteControl(
+			websocket.PongMessage, []byte(data), time.Now().Add(time.Second),
+		)
+	})
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(socketModeTimeout))
+
+		var envelope socketModeEnvelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			return err
+		}
+
+		// Every envelope with an id needs to be acknowledged, otherwise
+		// slack will send it again
+		if envelope.EnvelopeID != "" {
+			err := conn.WriteJSON(map[string]string{
+				"envelope_id": envelope.EnvelopeID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		switch envelope.Type {
+		case "hello":
+			s.IncomingEvents <- slack.RTMEvent{Type: "hello", Data: &slack.HelloEvent{}}
+		case "disconnect":
+			return errRefresh
+		case "events_api":
+			s.handleEvent(envelope.Payload.Event)
+		}
+	}
+}
+
+// handleEvent will create the event the same way the RTM does, with the
+// slack.EventMapping
+func (s *SocketMode) handleEvent(data json.RawMessage) {
+	var event struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		s.IncomingEvents <- slack.RTMEvent{
+			Type: "unmarshalling_error",
+			Data: &slack.UnmarshallingErrorEvent{ErrorObj: err},
+		}
+		return
+	}
+
+	v, ok := slack.EventMapping[event.Type]
+	if !ok {
+		// Events we don't know about are ignored, the app can be
+		// subscribed to more events than we handle
+		return
+	}
+
+	ev := reflect.New(reflect.TypeOf(v)).Interface()
+	if err := json.Unmarshal(data, ev); err != nil {
+		s.IncomingEvents <- slack.RTMEvent{
+			Type: "unmarshalling_error",
+			Data: &slack.UnmarshallingErrorEvent{ErrorObj: fmt.Errorf(
+				"couldn't unmarshal event %q: %s", event.Type, err.Error(),
+			)},
+		}
+		return
+	}
+
+	s.IncomingEvents <- slack.RTMEvent{Type: event.Type, Data: ev}
+}
@@ -0,0 +1,705 @@
+// Package slacktest provides a local stand-in for the slack Web API, the
+// RTM websocket and the Socket Mode websocket. It serves the workspace of
+// a service.FakeBackend over HTTP, so that the slack.Client and RTM code
+// paths of slack-term can be run without any outside services.
+//
+//	srv := slacktest.NewServer(service.NewFakeBackend())
+//	defer srv.Close()
+//
+//	cfg.APIURL = srv.URL()
+//	backend, _ := service.NewBackend(service.BackendSlack, cfg.GetWorkspaces()[0])
+//	svc, _ := service.NewSlackService(cfg, cfg.GetWorkspaces()[0], backend)
+package slacktest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+
+	"github.com/erroneousboat/slack-term/service"
+)
+
+// Server is the definition of the slack stand-in server
+type Server struct {
+	Workspace *service.FakeBackend
+
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+}
+
+// NewServer is the constructor for the Server, it will start listening on
+// a local port straight away
+func NewServer(workspace *service.FakeBackend) *Server {
+	s := &Server{
+		Workspace: workspace,
+		upgrader: websocket.Upgrader{
+			// The RTM sets the origin to https://api.slack.com
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/auth.test", s.handleAuthTest)
+	mux.HandleFunc("/api/rtm.connect", s.handleRTMConnect)
+	mux.HandleFunc("/api/users.list", s.handleUsersList)
+	mux.HandleFunc("/api/users.info", s.handleUsersInfo)
+	mux.HandleFunc("/api/users.getPresence", s.handleUsersGetPresence)
+	mux.HandleFunc("/api/users.setPresence", s.handleUsersSetPresence)
+	mux.HandleFunc("/api/users.prefs.get", s.handleUsersPrefsGet)
+	mux.HandleFunc("/api/usergroups.list", s.handleUserGroupsList)
+	mux.HandleFunc("/api/bots.info", s.handleBotsInfo)
+	mux.HandleFunc("/api/conversations.list", s.handleConversationsList)
+	mux.HandleFunc("/api/conversations.info", s.handleConversationsInfo)
+	mux.HandleFunc("/api/conversations.history", s.handleConversationsHistory)
+	mux.HandleFunc("/api/conversations.replies", s.handleConversationsReplies)
+	mux.HandleFunc("/api/conversations.open", s.handleConversationsOpen)
+	mux.HandleFunc("/api/conversations.join", s.handleConversationsJoin)
+	mux.HandleFunc("/api/conversations.leave", s.handleConversationsLeave)
+	mux.HandleFunc("/api/conversations.create", s.handleConversationsCreate)
+	mux.HandleFunc("/api/conversations.archive", s.handleConversationsArchive)
+	mux.HandleFunc("/api/search.messages", s.handleSearchMessages)
+	mux.HandleFunc("/api/chat.postMessage", s.handleChatPostMessage)
+	mux.HandleFunc("/api/chat.update", s.handleChatUpdate)
+	mux.HandleFunc("/api/chat.delete", s.handleChatDelete)
+	mux.HandleFunc("/api/chat.command", s.handleChatCommand)
+	mux.HandleFunc("/api/reactions.add", s.handleReactionsAdd)
+	mux.HandleFunc("/api/reactions.remove", s.handleReactionsRemove)
+	mux.HandleFunc("/api/channels.mark", s.handleMark)
+	mux.HandleFunc("/api/groups.mark", s.handleMark)
+	mux.HandleFunc("/api/im.mark", s.handleMark)
+	mux.HandleFunc("/api/apps.connections.open", s.handleAppsConnectionsOpen)
+	mux.HandleFunc("/ws", s.handleWebsocket)
+	mux.HandleFunc("/socket", s.handleSocketMode)
+
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+// URL returns the base url of the Web API, it can be used as the api_url
+// in the config
+func (s *Server) URL() string {
+	return s.server.URL + "/api/"
+}
+
+// Close will shut down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) handleAuthTest(w http.ResponseWriter, r *http.Request) {
+	auth, err := s.Workspace.AuthTest()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"team":    auth.Team,
+		"team_id": "T00000001",
+		"user":    auth.User,
+		"user_id": auth.UserID,
+		"url":     s.server.URL,
+	})
+}
+
+func (s *Server) handleRTMConnect(w http.ResponseWriter, r *http.Request) {
+	auth, err := s.Workspace.AuthTest()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"url": "ws" + strings.TrimPrefix(s.server.URL, "http") + "/ws",
+		"self": map[string]string{
+			"id":   auth.UserID,
+			"name": auth.User,
+		},
+		"team": map[string]string{
+			"id":     "T00000001",
+			"name":   auth.Team,
+			"domain": auth.Team,
+		},
+	})
+}
+
+func (s *Server) handleAppsConnectionsOpen(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		respondError(w, errors.New("not_authed"))
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"url": "ws" + strings.TrimPrefix(s.server.URL, "http") + "/socket",
+	})
+}
+
+func (s *Server) handleUsersList(w http.ResponseWriter, r *http.Request) {
+	users, err := s.Workspace.GetUsers()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"members": users,
+	})
+}
+
+func (s *Server) handleUsersInfo(w http.ResponseWriter, r *http.Request) {
+	user, err := s.Workspace.GetUserInfo(r.FormValue("user"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"user": user,
+	})
+}
+
+func (s *Server) handleUsersGetPresence(w http.ResponseWriter, r *http.Request) {
+	presence, err := s.Workspace.GetUserPresence(r.FormValue("user"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"presence": presence.Presence,
+		"online":   presence.Online,
+	})
+}
+
+func (s *Server) handleUsersSetPresence(w http.ResponseWriter, r *http.Request) {
+	if err := s.Workspace.SetUserPresence(r.FormValue("presence")); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, nil)
+}
+
+func (s *Server) handleUserGroupsList(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.Workspace.GetUserGroups()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"usergroups": groups,
+	})
+}
+
+// handleUsersPrefsGet responds with the notification preferences, slack
+// encodes all_notifications_prefs as a json string
+func (s *Server) handleUsersPrefsGet(w http.ResponseWriter, r *http.Request) {
+	prefs, err := s.Workspace.GetNotificationPrefs()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	channels := make(map[string]interface{})
+	for channelID, level := range prefs.Levels {
+		channels[channelID] = map[string]string{"desktop": level}
+	}
+
+	notifications, err := json.Marshal(map[string]interface{}{
+		"channels": channels,
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"prefs": map[string]string{
+			"muted_channels":          strings.Join(prefs.MutedChannels, ","),
+			"all_notifications_prefs": string(notifications),
+		},
+	})
+}
+
+func (s *Server) handleBotsInfo(w http.ResponseWriter, r *http.Request) {
+	bot, err := s.Workspace.GetBotInfo(r.FormValue("bot"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"bot": bot,
+	})
+}
+
+func (s *Server) handleConversationsList(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.FormValue("limit"))
+
+	var types []string
+	if r.FormValue("types") != "" {
+		types = strings.Split(r.FormValue("types"), ",")
+	}
+
+	chans, cursor, err := s.Workspace.GetConversations(
+		&slack.GetConversationsParameters{
+			Cursor:          r.FormValue("cursor"),
+			ExcludeArchived: r.FormValue("exclude_archived"),
+			Limit:           limit,
+			Types:           types,
+		},
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channels": chans,
+		"response_metadata": map[string]string{
+			"next_cursor": cursor,
+		},
+	})
+}
+
+func (s *Server) handleConversationsInfo(w http.ResponseWriter, r *http.Request) {
+	chn, err := s.Workspace.GetConversationInfo(
+		r.FormValue("channel"), r.FormValue("include_locale") == "true",
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel": chn,
+	})
+}
+
+func (s *Server) handleConversationsHistory(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.FormValue("limit"))
+
+	history, err := s.Workspace.GetConversationHistory(
+		&slack.GetConversationHistoryParameters{
+			ChannelID: r.FormValue("channel"),
+			Cursor:    r.FormValue("cursor"),
+			Inclusive: r.FormValue("inclusive") == "1",
+			Latest:    r.FormValue("latest"),
+			Limit:     limit,
+			Oldest:    r.FormValue("oldest"),
+		},
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, history)
+}
+
+func (s *Server) handleConversationsReplies(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.FormValue("limit"))
+
+	msgs, hasMore, cursor, err := s.Workspace.GetConversationReplies(
+		&slack.GetConversationRepliesParameters{
+			ChannelID: r.FormValue("channel"),
+			Timestamp: r.FormValue("ts"),
+			Cursor:    r.FormValue("cursor"),
+			Inclusive: r.FormValue("inclusive") == "1",
+			Latest:    r.FormValue("latest"),
+			Limit:     limit,
+			Oldest:    r.FormValue("oldest"),
+		},
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"messages": msgs,
+		"has_more": hasMore,
+		"response_metadata": map[string]string{
+			"next_cursor": cursor,
+		},
+	})
+}
+
+func (s *Server) handleConversationsOpen(w http.ResponseWriter, r *http.Request) {
+	params := &slack.OpenConversationParameters{
+		ChannelID: r.FormValue("channel"),
+		ReturnIM:  r.FormValue("return_im") == "true",
+	}
+	if users := r.FormValue("users"); users != "" {
+		params.Users = strings.Split(users, ",")
+	}
+
+	chn, noOp, alreadyOpen, err := s.Workspace.OpenConversation(params)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel":      chn,
+		"no_op":        noOp,
+		"already_open": alreadyOpen,
+	})
+}
+
+func (s *Server) handleConversationsJoin(w http.ResponseWriter, r *http.Request) {
+	chn, warning, _, err := s.Workspace.JoinConversation(r.FormValue("channel"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel": chn,
+		"warning": warning,
+	})
+}
+
+func (s *Server) handleConversationsLeave(w http.ResponseWriter, r *http.Request) {
+	notInChannel, err := s.Workspace.LeaveConversation(r.FormValue("channel"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"not_in_channel": notInChannel,
+	})
+}
+
+func (s *Server) handleConversationsCreate(w http.ResponseWriter, r *http.Request) {
+	chn, err := s.Workspace.CreateConversation(
+		r.FormValue("name"), r.FormValue("is_private") == "true",
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel": chn,
+	})
+}
+
+func (s *Server) handleConversationsArchive(w http.ResponseWriter, r *http.Request) {
+	if err := s.Workspace.ArchiveConversation(r.FormValue("channel")); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, nil)
+}
+
+func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
+	params := slack.NewSearchParameters()
+	if count, err := strconv.Atoi(r.FormValue("count")); err == nil {
+		params.Count = count
+	}
+	if page, err := strconv.Atoi(r.FormValue("page")); err == nil {
+		params.Page = page
+	}
+
+	messages, err := s.Workspace.SearchMessages(r.FormValue("query"), params)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"query":    r.FormValue("query"),
+		"messages": messages,
+	})
+}
+
+func (s *Server) handleChatPostMessage(w http.ResponseWriter, r *http.Request) {
+	options := []slack.MsgOption{
+		slack.MsgOptionText(r.FormValue("text"), false),
+	}
+
+	if r.FormValue("thread_ts") != "" {
+		options = append(options, slack.MsgOptionTS(r.FormValue("thread_ts")))
+	}
+
+	if r.FormValue("reply_broadcast") == "true" {
+		options = append(options, slack.MsgOptionBroadcast())
+	}
+
+	channel, ts, err := s.Workspace.PostMessage(r.FormValue("channel"), options...)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+	})
+}
+
+func (s *Server) handleChatUpdate(w http.ResponseWriter, r *http.Request) {
+	channel, ts, text, err := s.Workspace.UpdateMessage(
+		r.FormValue("channel"),
+		r.FormValue("ts"),
+		slack.MsgOptionText(r.FormValue("text"), false),
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+		"text":    text,
+	})
+}
+
+func (s *Server) handleChatDelete(w http.ResponseWriter, r *http.Request) {
+	channel, ts, err := s.Workspace.DeleteMessage(r.FormValue("channel"), r.FormValue("ts"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+	})
+}
+
+func (s *Server) handleChatCommand(w http.ResponseWriter, r *http.Request) {
+	respond(w, nil)
+}
+
+func (s *Server) handleReactionsAdd(w http.ResponseWriter, r *http.Request) {
+	err := s.Workspace.AddReaction(
+		r.FormValue("name"),
+		slack.NewRefToMessage(r.FormValue("channel"), r.FormValue("timestamp")),
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, nil)
+}
+
+func (s *Server) handleReactionsRemove(w http.ResponseWriter, r *http.Request) {
+	err := s.Workspace.RemoveReaction(
+		r.FormValue("name"),
+		slack.NewRefToMessage(r.FormValue("channel"), r.FormValue("timestamp")),
+	)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, nil)
+}
+
+func (s *Server) handleMark(w http.ResponseWriter, r *http.Request) {
+	if err := s.Workspace.MarkIMChannel(r.FormValue("channel"), r.FormValue("ts")); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, nil)
+}
+
+// handleWebsocket will upgrade the connection and deliver the events of
+// the workspace as RTM frames, the pings of the client are answered.
+func (s *Server) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	outgoing := make(chan interface{}, 50)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			var frame struct {
+				ID   int    `json:"id"`
+				Type string `json:"type"`
+			}
+
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+
+			if frame.Type == "ping" {
+				outgoing <- map[string]interface{}{
+					"type":     "pong",
+					"reply_to": frame.ID,
+				}
+			}
+		}
+	}()
+
+	outgoing <- map[string]string{"type": "hello"}
+
+	events := s.Workspace.Connect()
+	for {
+		var frame interface{}
+
+		select {
+		case <-done:
+			return
+		case frame = <-outgoing:
+		case ev := <-events:
+			// The connected event is created by the RTM itself
+			if _, ok := ev.Data.(*slack.ConnectedEvent); ok {
+				continue
+			}
+
+			// The connection is dropped, the RTM will report it and
+			// reconnect by itself
+			if _, ok := ev.Data.(*slack.DisconnectedEvent); ok {
+				return
+			}
+
+			frame, err = rtmFrame(ev)
+			if err != nil {
+				continue
+			}
+		}
+
+		if err := conn.WriteJSON(frame); err != nil {
+			return
+		}
+	}
+}
+
+// handleSocketMode will deliver the events of the workspace wrapped in the
+// envelopes of Socket Mode
+func (s *Server) handleSocketMode(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+
+	// The acknowledgements of the envelopes are read, but not checked
+	go func() {
+		defer close(done)
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(map[string]string{"type": "hello"}); err != nil {
+		return
+	}
+
+	events := s.Workspace.Connect()
+	for envelopeID := 1; ; envelopeID++ {
+		var ev slack.RTMEvent
+
+		select {
+		case <-done:
+			return
+		case ev = <-events:
+		}
+
+		switch ev.Data.(type) {
+		case *slack.ConnectedEvent, *slack.ConnectingEvent:
+			// The state of the connection is reported by the client
+			continue
+		case *slack.DisconnectedEvent:
+			return
+		}
+
+		frame, err := rtmFrame(ev)
+		if err != nil {
+			continue
+		}
+
+		err = conn.WriteJSON(map[string]interface{}{
+			"type":        "events_api",
+			"envelope_id": strconv.Itoa(envelopeID),
+			"payload": map[string]interface{}{
+				"type":  "event_callback",
+				"event": frame,
+			},
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rtmFrame will create the json representation of the event, making sure
+// the type is set, so that it can be parsed by the RTM
+func rtmFrame(ev slack.RTMEvent) (map[string]interface{}, error) {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make(map[string]interface{})
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, err
+	}
+
+	if _, ok := frame["type"]; !ok || frame["type"] == "" {
+		frame["type"] = ev.Type
+	}
+
+	return frame, nil
+}
+
+func respond(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{}
+	if v != nil {
+		json.Unmarshal(data, &response)
+	}
+	response["ok"] = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func respondError(w http.ResponseWriter, err error) {
+	// Rate limits are sent the way slack does, so that the client returns
+	// a slack.RateLimitedError
+	if e, ok := err.(*slack.RateLimitedError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":    false,
+		"error": err.Error(),
+	})
+}
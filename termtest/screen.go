@@ -0,0 +1,238 @@
+// Package termtest provides a virtual terminal screen on which the
+// components of slack-term can be rendered without a terminal. The
+// resulting screen can be turned into a plain-text or ANSI snapshot, and
+// compared against a golden file.
+//
+//	screen := termtest.NewScreen(80, 24)
+//	screen.Layout(view, false, false)
+//
+//	err := termtest.Golden("testdata/general.golden", screen.String(), *update)
+package termtest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/erroneousboat/termui"
+	runewidth "github.com/mattn/go-runewidth"
+
+	"github.com/erroneousboat/slack-term/views"
+)
+
+// Screen is the definition of a virtual terminal screen, it holds the
+// cells the same way termbox would after a termui.Render. It can be
+// rendered on from multiple goroutines, like the terminal.
+type Screen struct {
+	Width  int
+	Height int
+	Cells  [][]termui.Cell
+
+	mutex sync.Mutex
+}
+
+// NewScreen is the constructor for a Screen with the given size
+func NewScreen(width int, height int) *Screen {
+	s := &Screen{
+		Width:  width,
+		Height: height,
+	}
+
+	s.Clear()
+
+	return s
+}
+
+// Clear will empty the screen
+func (s *Screen) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Cells = make([][]termui.Cell, s.Height)
+	for y := range s.Cells {
+		s.Cells[y] = make([]termui.Cell, s.Width)
+		for x := range s.Cells[y] {
+			s.Cells[y][x] = termui.Cell{Ch: ' '}
+		}
+	}
+}
+
+// Render will set the cells of the buffers on the screen, from left to
+// right, just like termui.Render does
+func (s *Screen) Render(bs ...termui.Bufferer) {
+	for _, b := range bs {
+		buf := b.Buffer()
+
+		s.mutex.Lock()
+		for p, c := range buf.CellMap {
+			if !p.In(buf.Area) {
+				continue
+			}
+
+			if p.X < 0 || p.X >= s.Width || p.Y < 0 || p.Y >= s.Height {
+				continue
+			}
+
+			s.Cells[p.Y][p.X] = c
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// Layout will lay out the grid of the View at the size of the screen and
+// render it, in the same way slack-term does on startup
+func (s *Screen) Layout(view *views.View, threads bool, debug bool) {
+	grid := termui.NewGrid()
+	grid.X = 0
+	grid.Y = 0
+	grid.BgColor = termui.ThemeAttr("bg")
+	grid.Width = s.Width
+
+	view.Resize(s.Height)
+	view.Layout(grid, threads, debug)
+	grid.Align()
+
+	s.Clear()
+	s.Render(grid)
+}
+
+// String returns the plain-text snapshot of the screen, trailing spaces
+// of every line are removed
+func (s *Screen) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var buf bytes.Buffer
+
+	for _, row := range s.Cells {
+		var line strings.Builder
+		for x := 0; x < len(row); x++ {
+			line.WriteRune(row[x].Ch)
+
+			// Wide runes take up the next cell as well
+			x += runewidth.RuneWidth(row[x].Ch) - 1
+		}
+
+		buf.WriteString(strings.TrimRight(line.String(), " "))
+		buf.WriteByte('\n')
+	}
+
+	return buf.String()
+}
+
+// ANSI returns the snapshot of the screen, including the colors and
+// attributes of the cells as ANSI escape sequences
+func (s *Screen) ANSI() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var buf bytes.Buffer
+
+	for _, row := range s.Cells {
+		var fg, bg termui.Attribute
+		for x := 0; x < len(row); x++ {
+			cell := row[x]
+
+			if x == 0 || cell.Fg != fg || cell.Bg != bg {
+				buf.WriteString(sgr(cell.Fg, cell.Bg))
+				fg, bg = cell.Fg, cell.Bg
+			}
+
+			buf.WriteRune(cell.Ch)
+			x += runewidth.RuneWidth(cell.Ch) - 1
+		}
+
+		buf.WriteString("\x1b[0m\n")
+	}
+
+	return buf.String()
+}
+
+// sgr returns the escape sequence that sets the colors and attributes
+func sgr(fg termui.Attribute, bg termui.Attribute) string {
+	params := []string{"0"}
+
+	if fg&termui.AttrBold != 0 || bg&termui.AttrBold != 0 {
+		params = append(params, "1")
+	}
+
+	if fg&termui.AttrUnderline != 0 || bg&termui.AttrUnderline != 0 {
+		params = append(params, "4")
+	}
+
+	if fg&termui.AttrReverse != 0 || bg&termui.AttrReverse != 0 {
+		params = append(params, "7")
+	}
+
+	// Colors are numbered from ColorBlack (1) to ColorWhite (8), the
+	// lower bits hold the color
+	if c := fg & 0x1ff; c > termui.ColorDefault && c <= termui.ColorWhite {
+		params = append(params, fmt.Sprintf("%d", 29+int(c)))
+	}
+
+	if c := bg & 0x1ff; c > termui.ColorDefault && c <= termui.ColorWhite {
+		params = append(params, fmt.Sprintf("%d", 39+int(c)))
+	}
+
+	return fmt.Sprintf("\x1b[%sm", strings.Join(params, ";"))
+}
+
+// Golden will compare the snapshot with the contents of the golden file.
+// When update is true the golden file is written instead, use this to
+// create or update the fixture after an intended change.
+func Golden(filepath string, snapshot string, update bool) error {
+	if update {
+		if err := os.MkdirAll(fp.Dir(filepath), os.ModePerm); err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(filepath, []byte(snapshot), 0644)
+	}
+
+	golden, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return fmt.Errorf("couldn't read golden file, run with update to create it: (%v)", err)
+	}
+
+	if string(golden) == snapshot {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"snapshot doesn't match %s:\n%s",
+		filepath, diff(string(golden), snapshot),
+	)
+}
+
+// diff returns the lines that differ between the golden file and the
+// snapshot
+func diff(golden string, snapshot string) string {
+	want := strings.Split(golden, "\n")
+	got := strings.Split(snapshot, "\n")
+
+	n := len(want)
+	if len(got) > n {
+		n = len(got)
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+
+		if w != g {
+			fmt.Fprintf(&buf, "line %d:\n- %q\n+ %q\n", i+1, w, g)
+		}
+	}
+
+	return buf.String()
+}
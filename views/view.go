@@ -18,36 +18,61 @@ type View struct {
 	Debug    *components.Debug
 }
 
+// CreateView will create the View with the size of the terminal
 func CreateView(config *config.Config, svc *service.SlackService) (*View, error) {
+	return CreateViewWithHeight(config, svc, termui.TermHeight())
+}
+
+// CreateViewWithHeight will create the View for a terminal of the given
+// height, this allows the View to be created without a terminal
+func CreateViewWithHeight(config *config.Config, svc *service.SlackService, termHeight int) (*View, error) {
 	// Create Input component
 	input := components.CreateInputComponent()
 
 	// Channels: create the component
-	sideBarHeight := termui.TermHeight() - input.Par.Height
+	sideBarHeight := termHeight - input.Par.Height
 	channels := components.CreateChannelsComponent(sideBarHeight)
 
-	// Channels: fill the component
-	slackChans, err := svc.GetChannels()
-	if err != nil {
-		return nil, err
+	// Channels: fill the component, with the cached channels when there
+	// are any, they're reconciled with slack in the background
+	slackChans, ok := svc.GetCachedChannels()
+	if !ok {
+		var err error
+		slackChans, err = svc.GetChannels()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Channels: set channels in component
+	// Channels: set channels in component, in the sections of the sidebar
+	channels.Sidebar = svc.Workspace.Sidebar
 	channels.SetChannels(slackChans)
+	channels.MoveCursorTop()
 
 	// Threads: create component
 	threads := components.CreateThreadsComponent(sideBarHeight)
 
-	// Chat: create the component
-	chat := components.CreateChatComponent(input.Par.Height)
+	// Chat: create the component, the mentions of the user and the
+	// highlight rules are highlighted
+	chat := components.CreateChatComponent(sideBarHeight)
+	chat.Highlights = svc.GetHighlights()
+	chat.StyleHighlight = config.Theme.Message.Highlight
 
-	// Chat: fill the component
-	msgs, thr, err := svc.GetMessages(
+	// Chat: fill the component, with the cached messages when there are
+	// any
+	msgs, thr, ok := svc.GetCachedMessages(
 		channels.ChannelItems[channels.SelectedChannel].ID,
 		chat.GetMaxItems(),
 	)
-	if err != nil {
-		return nil, err
+	if !ok {
+		var err error
+		msgs, thr, err = svc.GetMessages(
+			channels.ChannelItems[channels.SelectedChannel].ID,
+			chat.GetMaxItems(),
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Chat: set messages in component
@@ -70,7 +95,7 @@ func CreateView(config *config.Config, svc *service.SlackService) (*View, error)
 	}
 
 	// Debug: create the component
-	debug := components.CreateDebugComponent(input.Par.Height)
+	debug := components.CreateDebugComponent(sideBarHeight)
 
 	// Mode: create the component
 	mode := components.CreateModeComponent()
@@ -97,3 +122,75 @@ func (v *View) Refresh() {
 		v.Mode,
 	)
 }
+
+// Layout will add the components of the View to the grid. The columns that
+// are created depend on whether the threads and/or the debug pane need to
+// be shown. When a thread is opened in the Chat pane, it will take up the
+// full width.
+func (v *View) Layout(grid *termui.Grid, threads bool, debug bool) {
+	columns := []*termui.Row{
+		termui.NewCol(v.Config.SidebarWidth, 0, v.Channels),
+	}
+
+	if v.Chat.Thread != "" && debug {
+		columns = []*termui.Row{
+			termui.NewCol(v.Config.SidebarWidth+v.Config.MainWidth-3, 0, v.Chat),
+			termui.NewCol(3, 0, v.Debug),
+		}
+	} else if v.Chat.Thread != "" {
+		columns = []*termui.Row{
+			termui.NewCol(v.Config.SidebarWidth+v.Config.MainWidth, 0, v.Chat),
+		}
+	} else if threads && debug {
+		columns = append(
+			columns,
+			[]*termui.Row{
+				termui.NewCol(v.Config.MainWidth-v.Config.ThreadsWidth-3, 0, v.Chat),
+				termui.NewCol(v.Config.ThreadsWidth, 0, v.Threads),
+				termui.NewCol(3, 0, v.Debug),
+			}...,
+		)
+	} else if threads {
+		columns = append(
+			columns,
+			[]*termui.Row{
+				termui.NewCol(v.Config.MainWidth-v.Config.ThreadsWidth, 0, v.Chat),
+				termui.NewCol(v.Config.ThreadsWidth, 0, v.Threads),
+			}...,
+		)
+	} else if debug {
+		columns = append(
+			columns,
+			[]*termui.Row{
+				termui.NewCol(v.Config.MainWidth-5, 0, v.Chat),
+				termui.NewCol(v.Config.MainWidth-6, 0, v.Debug),
+			}...,
+		)
+	} else {
+		columns = append(
+			columns,
+			[]*termui.Row{
+				termui.NewCol(v.Config.MainWidth, 0, v.Chat),
+			}...,
+		)
+	}
+
+	grid.AddRows(
+		termui.NewRow(columns...),
+		termui.NewRow(
+			termui.NewCol(v.Config.SidebarWidth, 0, v.Mode),
+			termui.NewCol(v.Config.MainWidth, 0, v.Input),
+		),
+	)
+}
+
+// Resize will set the height of the vertical panes, so that they fit a
+// terminal of the given height
+func (v *View) Resize(termHeight int) {
+	height := termHeight - v.Input.Par.Height
+
+	v.Channels.List.Height = height
+	v.Threads.List.Height = height
+	v.Chat.List.Height = height
+	v.Debug.List.Height = height
+}
@@ -0,0 +1,126 @@
+package views_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/erroneousboat/slack-term/components"
+	"github.com/erroneousboat/slack-term/config"
+	"github.com/erroneousboat/slack-term/service"
+	"github.com/erroneousboat/slack-term/termtest"
+	"github.com/erroneousboat/slack-term/views"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func init() {
+	// The fake workspace and the times of the messages are in UTC, so
+	// that the snapshots are the same in every time zone
+	time.Local = time.UTC
+}
+
+// newTestView returns the View of the fake workspace, the config can be
+// changed before the View is created
+func newTestView(t *testing.T, configure func(*config.Config)) (*views.View, *service.SlackService) {
+	t.Helper()
+
+	cfg, err := config.NewConfig("testdata/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configure != nil {
+		configure(cfg)
+	}
+
+	svc, err := service.NewSlackService(cfg, cfg.GetWorkspaces()[0], service.NewFakeBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view, err := views.CreateViewWithHeight(cfg, svc, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return view, svc
+}
+
+func TestView(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		setup     func(*testing.T, *views.View, *service.SlackService)
+		debug     bool
+	}{
+		{
+			name: "general",
+		},
+		{
+			name: "sections",
+			configure: func(cfg *config.Config) {
+				cfg.Sidebar.Favourites = []string{"#random"}
+				cfg.Sidebar.Sections = []config.Section{
+					{Name: "Private", Channels: []string{"#secret", "@bob"}},
+				}
+				cfg.Sidebar.Collapsed = []string{components.SectionDirectMessages}
+			},
+		},
+		{
+			name: "thread",
+			setup: func(t *testing.T, view *views.View, svc *service.SlackService) {
+				channelID := view.Channels.GetSelectedChannel().ID
+				threadID := view.Threads.ChannelItems[1].ID
+
+				parent, replies, cursor, err := svc.GetThread(
+					channelID, threadID, "", view.Chat.GetMaxItems(),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				view.Chat.OpenThread(parent, replies, cursor)
+			},
+		},
+		{
+			name: "insert",
+			setup: func(t *testing.T, view *views.View, svc *service.SlackService) {
+				view.Mode.SetInsertMode()
+				view.Input.SetText("Hello everyone")
+			},
+		},
+		{
+			name: "offline",
+			setup: func(t *testing.T, view *views.View, svc *service.SlackService) {
+				view.Mode.SetConnection(components.ConnectionOffline)
+			},
+		},
+		{
+			name:  "debug",
+			debug: true,
+			setup: func(t *testing.T, view *views.View, svc *service.SlackService) {
+				view.Debug.List.Items = []string{"Loading the channels"}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view, svc := newTestView(t, test.configure)
+			if test.setup != nil {
+				test.setup(t, view, svc)
+			}
+
+			screen := termtest.NewScreen(120, 30)
+			screen.Layout(view, len(view.Threads.ChannelItems) > 0, test.debug)
+
+			err := termtest.Golden(
+				"testdata/"+test.name+".golden", screen.String(), *update,
+			)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}